@@ -0,0 +1,141 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsm
+
+// Edge is a transition of the model: the event Event leads from the state Src
+// to the state Dst.
+type Edge[STATE, EVENT comparable] struct {
+	Src   STATE
+	Event EVENT
+	Dst   STATE
+}
+
+// Edges returns the transitions of the model, sorted by source state then
+// event.
+func (f *FSM[STATE, EVENT, FSM_IMPL, ARG]) Edges() []Edge[STATE, EVENT] {
+	keys := f.getSortedTransitionKeys()
+	edges := make([]Edge[STATE, EVENT], len(keys))
+	for i, key := range keys {
+		edges[i] = Edge[STATE, EVENT]{Src: key.src, Event: key.event, Dst: f.transitions[key]}
+	}
+	return edges
+}
+
+// Reachable returns the states that can be reached from the state from by
+// any sequence of events, from itself included, nearest first.
+func (f *FSM[STATE, EVENT, FSM_IMPL, ARG]) Reachable(from STATE) []STATE {
+	order, _ := f.bfs(from, nil)
+	return order
+}
+
+// PathTo returns a shortest sequence of events leading from the state from to
+// the state to. It returns nil if to cannot be reached, and an empty slice if
+// from and to are the same state.
+//
+// Among paths of the same length, the one whose events sort first is
+// returned, so the result is stable.
+func (f *FSM[STATE, EVENT, FSM_IMPL, ARG]) PathTo(from, to STATE) []EVENT {
+	return f.path(from, to, nil)
+}
+
+// path is like PathTo but ignores the transitions in skip.
+func (f *FSM[STATE, EVENT, FSM_IMPL, ARG]) path(from, to STATE, skip map[eKey[STATE, EVENT]]bool) []EVENT {
+	_, parents := f.bfs(from, skip)
+	if _, ok := parents[to]; !ok {
+		return nil
+	}
+	var path []EVENT
+	for s := to; s != from; {
+		key := parents[s]
+		path = append(path, key.event)
+		s = key.src
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	if path == nil {
+		path = []EVENT{}
+	}
+	return path
+}
+
+// CanReach returns true if the state to can be reached from the state from.
+func (f *FSM[STATE, EVENT, FSM_IMPL, ARG]) CanReach(from, to STATE) bool {
+	_, parents := f.bfs(from, nil)
+	_, ok := parents[to]
+	return ok
+}
+
+// DeadStates returns the states that cannot be left once entered: the states
+// without a transition to another state. These are usually the terminal
+// states of the model.
+func (f *FSM[STATE, EVENT, FSM_IMPL, ARG]) DeadStates() []STATE {
+	adjacency := f.adjacency()
+	var dead []STATE
+	for _, s := range f.states() {
+		alive := false
+		for _, key := range adjacency[s] {
+			if f.transitions[key] != s {
+				alive = true
+				break
+			}
+		}
+		if !alive {
+			dead = append(dead, s)
+		}
+	}
+	return dead
+}
+
+// states returns the initial state and the states of the transitions, sorted.
+func (f *FSM[STATE, EVENT, FSM_IMPL, ARG]) states() []STATE {
+	states, ids := f.getSortedStates()
+	if _, ok := ids[f.initial]; !ok {
+		states = append([]STATE{f.initial}, states...)
+	}
+	return states
+}
+
+// adjacency returns the transitions leaving each state, sorted by event.
+func (f *FSM[STATE, EVENT, FSM_IMPL, ARG]) adjacency() map[STATE][]eKey[STATE, EVENT] {
+	adjacency := make(map[STATE][]eKey[STATE, EVENT])
+	for _, key := range f.getSortedTransitionKeys() {
+		adjacency[key.src] = append(adjacency[key.src], key)
+	}
+	return adjacency
+}
+
+// bfs walks the transitions breadth first from the state from. It returns
+// the states in the order they were reached and, for each of them, the
+// transition it was first reached by. The state from maps to the zero key.
+// The transitions in skip are ignored.
+func (f *FSM[STATE, EVENT, FSM_IMPL, ARG]) bfs(from STATE, skip map[eKey[STATE, EVENT]]bool) ([]STATE, map[STATE]eKey[STATE, EVENT]) {
+	adjacency := f.adjacency()
+	order := []STATE{from}
+	parents := map[STATE]eKey[STATE, EVENT]{from: {}}
+	for i := 0; i < len(order); i++ {
+		for _, key := range adjacency[order[i]] {
+			if skip[key] {
+				continue
+			}
+			dst := f.transitions[key]
+			if _, ok := parents[dst]; !ok {
+				parents[dst] = key
+				order = append(order, dst)
+			}
+		}
+	}
+	return order, parents
+}
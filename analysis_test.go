@@ -0,0 +1,69 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func newOrderFSM() *FSM[string, string, door, any] {
+	return NewFSM[string, string, door, any]("cart", []EventDesc[string, string]{
+		{Name: "checkout", Src: []string{"cart"}, Dst: "pending"},
+		{Name: "pay", Src: []string{"pending"}, Dst: "paid"},
+		{Name: "express", Src: []string{"cart"}, Dst: "paid"},
+		{Name: "ship", Src: []string{"paid"}, Dst: "shipped"},
+		{Name: "cancel", Src: []string{"cart", "pending"}, Dst: "canceled"},
+		{Name: "edit", Src: []string{"cart"}, Dst: "cart"},
+	})
+}
+
+func TestReachability(t *testing.T) {
+	f := newOrderFSM()
+
+	if got := f.Reachable("pending"); !reflect.DeepEqual(got, []string{"pending", "canceled", "paid", "shipped"}) {
+		t.Errorf("unexpected reachable states %v", got)
+	}
+	if got := f.PathTo("cart", "shipped"); !reflect.DeepEqual(got, []string{"express", "ship"}) {
+		t.Errorf("expected shortest path [express ship], got %v", got)
+	}
+	if got := f.PathTo("cart", "cart"); got == nil || len(got) != 0 {
+		t.Errorf("expected empty path to the same state, got %#v", got)
+	}
+	if got := f.PathTo("shipped", "cart"); got != nil {
+		t.Errorf("expected no path, got %v", got)
+	}
+	if !f.CanReach("cart", "canceled") || f.CanReach("paid", "canceled") {
+		t.Error("unexpected CanReach result")
+	}
+	if got := f.DeadStates(); !reflect.DeepEqual(got, []string{"canceled", "shipped"}) {
+		t.Errorf("unexpected dead states %v", got)
+	}
+
+	for _, s := range f.Reachable("cart") {
+		if !f.CanReach(s, "shipped") && !f.CanReach(s, "canceled") {
+			t.Errorf("state %s cannot reach a terminal state", s)
+		}
+	}
+}
+
+func TestDeadStatesIgnoreSelfLoops(t *testing.T) {
+	f := NewFSM[string, string, door, any]("idle", []EventDesc[string, string]{
+		{Name: "ping", Src: []string{"idle"}, Dst: "idle"},
+	})
+	if got := f.DeadStates(); !reflect.DeepEqual(got, []string{"idle"}) {
+		t.Errorf("expected idle to be dead, got %v", got)
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsm
+
+import "time"
+
+// Clock provides the time to the FSM. Everything time based goes through it:
+// history timestamps, observed durations, dwell times and timeouts.
+type Clock interface {
+	Now() time.Time
+
+	// NewTimer creates a timer sending the current time on its channel after
+	// the duration d elapsed.
+	NewTimer(d time.Duration) Timer
+
+	// AfterFunc calls f in its own goroutine after the duration d elapsed,
+	// unless the returned timer is stopped before.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a single event scheduled by a Clock, like time.Timer.
+type Timer interface {
+	// C returns the channel the time is sent on when the timer fires. It is
+	// nil for timers created by Clock.AfterFunc.
+	C() <-chan time.Time
+
+	// Stop prevents the timer from firing. It returns false if the timer
+	// already fired or was stopped.
+	Stop() bool
+
+	// Reset changes the timer to fire after the duration d. It returns true
+	// if the timer was active.
+	Reset(d time.Duration) bool
+}
+
+// SystemClock is the Clock using the system time and timers. It is the
+// default clock.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) NewTimer(d time.Duration) Timer { return systemTimer{time.NewTimer(d)} }
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return systemTimer{time.AfterFunc(d, f)}
+}
+
+type systemTimer struct {
+	*time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time { return t.Timer.C }
+
+// SetClock sets the clock used by the model and the instances created
+// afterwards.
+func (f *FSM[STATE, EVENT, FSM_IMPL, ARG]) SetClock(clock Clock) *FSM[STATE, EVENT, FSM_IMPL, ARG] {
+	f.clock = clock
+	return f
+}
+
+// SetClock sets the clock used by the instance instead of the clock of the
+// model. The current state is considered entered now, and its timeout, if
+// any, is rescheduled with clock. It must be called before the instance is
+// shared.
+func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) SetClock(clock Clock) *Instance[STATE, EVENT, FSM_IMPL, ARG] {
+	f.clock = clock
+	for {
+		s := f.load()
+		restamped := newSnapshot(s.state, s.version, clock.Now())
+		restamped.stay = s.stay + 1
+		if f.state.CompareAndSwap(s, restamped) {
+			close(s.replaced)
+			break
+		}
+	}
+	f.rearm()
+	return f
+}
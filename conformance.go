@@ -0,0 +1,128 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsm
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Step is a transition recorded outside of the package, for example in an
+// audit table: the event Event moved an instance from Src to Dst.
+type Step[STATE, EVENT comparable] struct {
+	Event EVENT
+	Src   STATE
+	Dst   STATE
+}
+
+// DivergenceKind tells how a Step diverges from a model.
+type DivergenceKind string
+
+const (
+	// DivergenceUnknownEvent is reported when the event is not defined.
+	DivergenceUnknownEvent DivergenceKind = "unknown event"
+	// DivergenceInvalidTransition is reported when the event cannot occur
+	// in the source state.
+	DivergenceInvalidTransition DivergenceKind = "invalid transition"
+	// DivergenceDestinationMismatch is reported when the event leads to
+	// another state than the destination recorded.
+	DivergenceDestinationMismatch DivergenceKind = "destination mismatch"
+	// DivergenceSourceMismatch is reported when the source state is not the
+	// destination of the previous step, which suggests missing steps or a
+	// state changed without event.
+	DivergenceSourceMismatch DivergenceKind = "source mismatch"
+)
+
+// Divergence is a step of a trace that does not conform to a model.
+type Divergence[STATE, EVENT comparable] struct {
+	// Index is the index of the step in the trace.
+	Index int
+	Step  Step[STATE, EVENT]
+	Kind  DivergenceKind
+	// Expected is the state the model expected: the destination of the
+	// event for DivergenceDestinationMismatch and the destination of the
+	// previous step for DivergenceSourceMismatch.
+	Expected STATE
+}
+
+func (d Divergence[STATE, EVENT]) String() string {
+	s := fmt.Sprintf("step %d: event %v from %v to %v: %s", d.Index, d.Step.Event, d.Step.Src, d.Step.Dst, d.Kind)
+	switch d.Kind {
+	case DivergenceDestinationMismatch:
+		s += fmt.Sprintf(", model leads to %v", d.Expected)
+	case DivergenceSourceMismatch:
+		s += fmt.Sprintf(", previous step led to %v", d.Expected)
+	}
+	return s
+}
+
+// Report is the result of Conforms.
+type Report[STATE, EVENT comparable] struct {
+	// Steps is the number of steps checked.
+	Steps int
+	// Divergences are the steps that do not conform, in order.
+	Divergences []Divergence[STATE, EVENT]
+}
+
+// Conforms returns true if no step diverges from the model.
+func (r Report[STATE, EVENT]) Conforms() bool {
+	return len(r.Divergences) == 0
+}
+
+// String outputs the divergences, one per line.
+func (r Report[STATE, EVENT]) String() string {
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("%d steps, %d divergences\n", r.Steps, len(r.Divergences)))
+	for _, d := range r.Divergences {
+		buf.WriteString(d.String())
+		buf.WriteString("\n")
+	}
+	return buf.String()
+}
+
+// Conforms replays a trace recorded for a single instance against model and
+// reports every step diverging from it. Callbacks are not called.
+//
+// A step may diverge in several ways, its source state being unexpected and
+// its event invalid for instance. Each step is checked from its own source
+// state, and the replay goes on from its recorded destination whatever the
+// model says, so that a divergence is reported once rather than making all
+// the following steps diverge.
+func Conforms[STATE, EVENT comparable, FSM_IMPL, ARG any](model *FSM[STATE, EVENT, FSM_IMPL, ARG], trace []Step[STATE, EVENT]) Report[STATE, EVENT] {
+	events := make(map[EVENT]bool)
+	for key := range model.transitions {
+		events[key.event] = true
+	}
+
+	r := Report[STATE, EVENT]{Steps: len(trace)}
+	diverge := func(i int, kind DivergenceKind, expected STATE) {
+		r.Divergences = append(r.Divergences, Divergence[STATE, EVENT]{Index: i, Step: trace[i], Kind: kind, Expected: expected})
+	}
+	for i, step := range trace {
+		if i > 0 && step.Src != trace[i-1].Dst {
+			diverge(i, DivergenceSourceMismatch, trace[i-1].Dst)
+		}
+		dst, ok := model.transitions[eKey[STATE, EVENT]{step.Event, step.Src}]
+		switch {
+		case !events[step.Event]:
+			diverge(i, DivergenceUnknownEvent, step.Dst)
+		case !ok:
+			diverge(i, DivergenceInvalidTransition, step.Dst)
+		case dst != step.Dst:
+			diverge(i, DivergenceDestinationMismatch, dst)
+		}
+	}
+	return r
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsm
+
+import "testing"
+
+func TestConforms(t *testing.T) {
+	f := newOrderFSM()
+	type S = Step[string, string]
+
+	if r := Conforms(f, []S{{"checkout", "cart", "pending"}, {"pay", "pending", "paid"}, {"ship", "paid", "shipped"}}); !r.Conforms() || r.Steps != 3 {
+		t.Errorf("expected trace to conform, got %s", r)
+	}
+
+	r := Conforms(f, []S{
+		{"checkout", "cart", "pending"},
+		{"pay", "pending", "shipped"},
+		{"refund", "shipped", "refunded"},
+		{"ship", "paid", "shipped"},
+		{"checkout", "shipped", "pending"},
+	})
+	expected := `5 steps, 4 divergences
+step 1: event pay from pending to shipped: destination mismatch, model leads to paid
+step 2: event refund from shipped to refunded: unknown event
+step 3: event ship from paid to shipped: source mismatch, previous step led to refunded
+step 4: event checkout from shipped to pending: invalid transition
+`
+	if r.Conforms() || r.String() != expected {
+		t.Errorf("unexpected report:\n%s", r)
+	}
+	if d := r.Divergences[0]; d.Kind != DivergenceDestinationMismatch || d.Expected != "paid" || d.Index != 1 {
+		t.Errorf("unexpected divergence %+v", d)
+	}
+}
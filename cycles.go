@@ -0,0 +1,213 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsm
+
+import "sort"
+
+// CycleReport gathers the cyclic structure of a model.
+type CycleReport[STATE, EVENT comparable] struct {
+	// Components are the strongly connected components of the model, see
+	// FSM.SCCs.
+	Components [][]STATE
+	// Cycles are the elementary cycles of the model, see FSM.Cycles.
+	Cycles [][]Edge[STATE, EVENT]
+	// Truncated is true if more cycles exist than listed.
+	Truncated bool
+	// Livelocks are the states that cannot reach a dead state, see
+	// FSM.Livelocks.
+	Livelocks []STATE
+}
+
+// Highlight maps the edges of every cycle to color, to be given to
+// FSM.VisualizeHighlighted.
+func (r CycleReport[STATE, EVENT]) Highlight(color string) map[Edge[STATE, EVENT]]string {
+	highlight := make(map[Edge[STATE, EVENT]]string)
+	for _, cycle := range r.Cycles {
+		for _, edge := range cycle {
+			highlight[edge] = color
+		}
+	}
+	return highlight
+}
+
+// CycleReport returns the strongly connected components, the livelocks and
+// at most limit elementary cycles of the model.
+func (f *FSM[STATE, EVENT, FSM_IMPL, ARG]) CycleReport(limit int) CycleReport[STATE, EVENT] {
+	cycles, truncated := f.Cycles(limit)
+	return CycleReport[STATE, EVENT]{
+		Components: f.SCCs(),
+		Cycles:     cycles,
+		Truncated:  truncated,
+		Livelocks:  f.Livelocks(),
+	}
+}
+
+// SCCs returns the strongly connected components of the model: the largest
+// sets of states that can all reach each other. Every state belongs to
+// exactly one component, possibly alone. The components are listed in
+// topological order, no transition leading from a component to a previous
+// one, and the states of a component are sorted.
+func (f *FSM[STATE, EVENT, FSM_IMPL, ARG]) SCCs() [][]STATE {
+	// Tarjan's algorithm, which finds the components in reverse topological
+	// order.
+	adjacency := f.adjacency()
+	index := make(map[STATE]int)
+	low := make(map[STATE]int)
+	onStack := make(map[STATE]bool)
+	var stack []STATE
+	var components [][]STATE
+
+	var visit func(s STATE)
+	visit = func(s STATE) {
+		index[s] = len(index)
+		low[s] = index[s]
+		stack = append(stack, s)
+		onStack[s] = true
+		for _, key := range adjacency[s] {
+			dst := f.transitions[key]
+			if _, ok := index[dst]; !ok {
+				visit(dst)
+				if low[dst] < low[s] {
+					low[s] = low[dst]
+				}
+			} else if onStack[dst] && index[dst] < low[s] {
+				low[s] = index[dst]
+			}
+		}
+		if low[s] != index[s] {
+			return
+		}
+		var component []STATE
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			component = append(component, top)
+			if top == s {
+				break
+			}
+		}
+		components = append(components, component)
+	}
+
+	states := f.states()
+	for _, s := range states {
+		if _, ok := index[s]; !ok {
+			visit(s)
+		}
+	}
+
+	// Sort the states of each component and reverse the components.
+	rank := make(map[STATE]int, len(states))
+	for i, s := range states {
+		rank[s] = i
+	}
+	for _, component := range components {
+		sort.Slice(component, func(i, j int) bool { return rank[component[i]] < rank[component[j]] })
+	}
+	for i, j := 0, len(components)-1; i < j; i, j = i+1, j-1 {
+		components[i], components[j] = components[j], components[i]
+	}
+	return components
+}
+
+// Cycles returns the elementary cycles of the model, the sequences of
+// transitions leading back to their first state without going through a
+// state twice. Self transitions are cycles of one edge. At most limit cycles
+// are returned, all of them if limit is not positive, and truncated is true
+// if some were left out.
+//
+// Each cycle starts from its first state in sorted order, and the cycles are
+// sorted by that state then by their edges.
+func (f *FSM[STATE, EVENT, FSM_IMPL, ARG]) Cycles(limit int) (cycles [][]Edge[STATE, EVENT], truncated bool) {
+	adjacency := f.adjacency()
+	states := f.states()
+	rank := make(map[STATE]int, len(states))
+	for i, s := range states {
+		rank[s] = i
+	}
+
+	var path []Edge[STATE, EVENT]
+	visited := make(map[STATE]bool)
+	// walk extends path from s with the states ranked after start, and
+	// returns false once limit is exceeded.
+	var walk func(start, s STATE) bool
+	walk = func(start, s STATE) bool {
+		visited[s] = true
+		defer delete(visited, s)
+		for _, key := range adjacency[s] {
+			dst := f.transitions[key]
+			edge := Edge[STATE, EVENT]{Src: s, Event: key.event, Dst: dst}
+			switch {
+			case dst == start:
+				if limit > 0 && len(cycles) == limit {
+					return false
+				}
+				cycle := make([]Edge[STATE, EVENT], len(path), len(path)+1)
+				copy(cycle, path)
+				cycles = append(cycles, append(cycle, edge))
+			case rank[dst] > rank[start] && !visited[dst]:
+				path = append(path, edge)
+				ok := walk(start, dst)
+				path = path[:len(path)-1]
+				if !ok {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	for _, s := range states {
+		if !walk(s, s) {
+			return cycles, true
+		}
+	}
+	return cycles, false
+}
+
+// Livelocks returns the states from which no dead state can be reached: once
+// in them, an instance can only go round in cycles. The states are sorted.
+//
+// Models whose instances are not meant to terminate, like a door that opens
+// and closes forever, have no dead state and report all of their states.
+func (f *FSM[STATE, EVENT, FSM_IMPL, ARG]) Livelocks() []STATE {
+	// Walk the transitions backwards from the dead states.
+	reverse := make(map[STATE][]STATE)
+	for key, dst := range f.transitions {
+		reverse[dst] = append(reverse[dst], key.src)
+	}
+	alive := make(map[STATE]bool)
+	queue := f.DeadStates()
+	for _, s := range queue {
+		alive[s] = true
+	}
+	for i := 0; i < len(queue); i++ {
+		for _, src := range reverse[queue[i]] {
+			if !alive[src] {
+				alive[src] = true
+				queue = append(queue, src)
+			}
+		}
+	}
+
+	var livelocks []STATE
+	for _, s := range f.states() {
+		if !alive[s] {
+			livelocks = append(livelocks, s)
+		}
+	}
+	return livelocks
+}
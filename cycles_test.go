@@ -0,0 +1,97 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newJobFSM() *FSM[string, string, door, any] {
+	return NewFSM[string, string, door, any]("new", []EventDesc[string, string]{
+		{Name: "run", Src: []string{"new"}, Dst: "running"},
+		{Name: "tick", Src: []string{"running"}, Dst: "running"},
+		{Name: "finish", Src: []string{"running"}, Dst: "done"},
+		{Name: "fail", Src: []string{"running"}, Dst: "failed"},
+		{Name: "retry", Src: []string{"failed"}, Dst: "running"},
+		{Name: "park", Src: []string{"failed"}, Dst: "parked"},
+		{Name: "poke", Src: []string{"parked"}, Dst: "waiting"},
+		{Name: "poke", Src: []string{"waiting"}, Dst: "parked"},
+	})
+}
+
+func TestCycleReport(t *testing.T) {
+	r := newJobFSM().CycleReport(0)
+
+	components := [][]string{{"new"}, {"failed", "running"}, {"parked", "waiting"}, {"done"}}
+	if !reflect.DeepEqual(r.Components, components) {
+		t.Errorf("unexpected components %v", r.Components)
+	}
+
+	type E = Edge[string, string]
+	cycles := [][]E{
+		{{"failed", "retry", "running"}, {"running", "fail", "failed"}},
+		{{"parked", "poke", "waiting"}, {"waiting", "poke", "parked"}},
+		{{"running", "tick", "running"}},
+	}
+	if !reflect.DeepEqual(r.Cycles, cycles) || r.Truncated {
+		t.Errorf("unexpected cycles %v", r.Cycles)
+	}
+	if !reflect.DeepEqual(r.Livelocks, []string{"parked", "waiting"}) {
+		t.Errorf("unexpected livelocks %v", r.Livelocks)
+	}
+
+	if cycles, truncated := newJobFSM().Cycles(2); len(cycles) != 2 || !truncated {
+		t.Errorf("expected 2 cycles out of 3, got %d, truncated %v", len(cycles), truncated)
+	}
+}
+
+func TestVisualizeHighlighted(t *testing.T) {
+	f := newJobFSM()
+	highlight := f.CycleReport(0).Highlight("red")
+
+	graphviz, err := f.VisualizeHighlighted(GRAPHVIZ, "new", highlight)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(graphviz, `"waiting" -> "parked" [ label = "poke" color = "red" fontcolor = "red" ];`) ||
+		!strings.Contains(graphviz, `"running" -> "done" [ label = "finish" ];`) {
+		t.Errorf("unexpected graphviz output:\n%s", graphviz)
+	}
+
+	flowChart, err := f.VisualizeHighlighted(MermaidFlowChart, "new", highlight)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Links are numbered in sorted order: failed park, failed retry, new
+	// run, parked poke, running fail, running finish, running tick, waiting
+	// poke.
+	for _, link := range []string{"1", "3", "4", "6", "7"} {
+		if !strings.Contains(flowChart, "    linkStyle "+link+" stroke:red\n") {
+			t.Errorf("expected link %s to be highlighted:\n%s", link, flowChart)
+		}
+	}
+	if strings.Count(flowChart, "linkStyle") != 5 {
+		t.Errorf("expected 5 highlighted links:\n%s", flowChart)
+	}
+
+	if _, err := f.VisualizeHighlighted(MermaidStateDiagram, "new", highlight); err == nil {
+		t.Error("expected state diagram highlighting to be refused")
+	}
+	if plain, _ := f.VisualizeWithType(GRAPHVIZ, "new"); strings.Contains(plain, "color") {
+		t.Errorf("expected plain output without colors:\n%s", plain)
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsm
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+const (
+	diffAddedColor   = "#00AA00"
+	diffRemovedColor = "#AA0000"
+)
+
+// Redirection is a transition whose destination state changed.
+type Redirection[STATE, EVENT comparable] struct {
+	Src    STATE
+	Event  EVENT
+	OldDst STATE
+	NewDst STATE
+}
+
+// ModelDiff is the structural difference between two versions of a model, as
+// returned by Diff. States and transitions are sorted.
+type ModelDiff[STATE, EVENT comparable] struct {
+	OldInitial STATE
+	NewInitial STATE
+
+	AddedStates   []STATE
+	RemovedStates []STATE
+
+	AddedTransitions      []Edge[STATE, EVENT]
+	RemovedTransitions    []Edge[STATE, EVENT]
+	RedirectedTransitions []Redirection[STATE, EVENT]
+
+	// edges are the transitions of both versions, with unchanged ones,
+	// sorted for the diagram.
+	edges []diffEdge[STATE, EVENT]
+	// states are the states of both versions, sorted.
+	states []STATE
+}
+
+// diffEdge is a transition of either version. Its destination in a version
+// without it is absent.
+type diffEdge[STATE, EVENT comparable] struct {
+	src, oldDst, newDst STATE
+	event               EVENT
+	inOld, inNew        bool
+}
+
+// Diff compares two versions of a model: the states and transitions the
+// version to has and the version from does not, the other way round, the
+// transitions whose destination changed, and the initial states. Callbacks
+// are not compared.
+func Diff[STATE, EVENT comparable, FSM_IMPL, ARG any](from, to *FSM[STATE, EVENT, FSM_IMPL, ARG]) ModelDiff[STATE, EVENT] {
+	d := ModelDiff[STATE, EVENT]{OldInitial: from.initial, NewInitial: to.initial}
+
+	oldStates := make(map[STATE]bool)
+	for _, s := range from.states() {
+		oldStates[s] = true
+	}
+	newStates := make(map[STATE]bool)
+	for _, s := range to.states() {
+		newStates[s] = true
+		if !oldStates[s] {
+			d.AddedStates = append(d.AddedStates, s)
+		}
+	}
+	for _, s := range from.states() {
+		if !newStates[s] {
+			d.RemovedStates = append(d.RemovedStates, s)
+		}
+		d.states = append(d.states, s)
+	}
+	d.states = append(d.states, d.AddedStates...)
+	sort.Slice(d.states, func(i, j int) bool { return fmt.Sprint(d.states[i]) < fmt.Sprint(d.states[j]) })
+
+	for _, key := range from.getSortedTransitionKeys() {
+		e := diffEdge[STATE, EVENT]{src: key.src, event: key.event, oldDst: from.transitions[key], inOld: true}
+		e.newDst, e.inNew = to.transitions[key]
+		d.edges = append(d.edges, e)
+		switch {
+		case !e.inNew:
+			d.RemovedTransitions = append(d.RemovedTransitions, Edge[STATE, EVENT]{Src: e.src, Event: e.event, Dst: e.oldDst})
+		case e.oldDst != e.newDst:
+			d.RedirectedTransitions = append(d.RedirectedTransitions, Redirection[STATE, EVENT]{Src: e.src, Event: e.event, OldDst: e.oldDst, NewDst: e.newDst})
+		}
+	}
+	for _, key := range to.getSortedTransitionKeys() {
+		if _, ok := from.transitions[key]; !ok {
+			e := diffEdge[STATE, EVENT]{src: key.src, event: key.event, newDst: to.transitions[key], inNew: true}
+			d.edges = append(d.edges, e)
+			d.AddedTransitions = append(d.AddedTransitions, Edge[STATE, EVENT]{Src: e.src, Event: e.event, Dst: e.newDst})
+		}
+	}
+	sort.SliceStable(d.edges, func(i, j int) bool {
+		if d.edges[i].src == d.edges[j].src {
+			return fmt.Sprint(d.edges[i].event) < fmt.Sprint(d.edges[j].event)
+		}
+		return fmt.Sprint(d.edges[i].src) < fmt.Sprint(d.edges[j].src)
+	})
+	return d
+}
+
+// Empty returns true if the versions have the same structure.
+func (d ModelDiff[STATE, EVENT]) Empty() bool {
+	return d.OldInitial == d.NewInitial &&
+		len(d.AddedStates) == 0 && len(d.RemovedStates) == 0 &&
+		len(d.AddedTransitions) == 0 && len(d.RemovedTransitions) == 0 &&
+		len(d.RedirectedTransitions) == 0
+}
+
+// String outputs the diff as text, one change per line: + for additions, -
+// for removals and ~ for redirections.
+func (d ModelDiff[STATE, EVENT]) String() string {
+	var buf bytes.Buffer
+	if d.OldInitial != d.NewInitial {
+		buf.WriteString(fmt.Sprintf("~ initial %v (was %v)\n", d.NewInitial, d.OldInitial))
+	}
+	for _, s := range d.AddedStates {
+		buf.WriteString(fmt.Sprintf("+ state %v\n", s))
+	}
+	for _, s := range d.RemovedStates {
+		buf.WriteString(fmt.Sprintf("- state %v\n", s))
+	}
+	for _, e := range d.AddedTransitions {
+		buf.WriteString(fmt.Sprintf("+ %v --%v--> %v\n", e.Src, e.Event, e.Dst))
+	}
+	for _, e := range d.RemovedTransitions {
+		buf.WriteString(fmt.Sprintf("- %v --%v--> %v\n", e.Src, e.Event, e.Dst))
+	}
+	for _, r := range d.RedirectedTransitions {
+		buf.WriteString(fmt.Sprintf("~ %v --%v--> %v (was %v)\n", r.Src, r.Event, r.NewDst, r.OldDst))
+	}
+	return buf.String()
+}
+
+// Mermaid outputs both versions in a single Mermaid flow chart, coloring
+// added states and transitions in green and removed ones in red. A
+// redirected transition is drawn twice, removed then added, and a changed
+// initial state is noted in a comment.
+func (d ModelDiff[STATE, EVENT]) Mermaid() string {
+	var buf bytes.Buffer
+
+	ids := make(map[STATE]string, len(d.states))
+	for i, s := range d.states {
+		ids[s] = fmt.Sprintf("id%d", i)
+	}
+
+	buf.WriteString("graph LR\n")
+	if d.OldInitial != d.NewInitial {
+		buf.WriteString(fmt.Sprintf("    %%%% initial state changed from %v to %v\n", d.OldInitial, d.NewInitial))
+	}
+	for _, s := range d.states {
+		buf.WriteString(fmt.Sprintf("    %s[%v]\n", ids[s], s))
+	}
+	buf.WriteString("\n")
+
+	var styles []string
+	link := 0
+	writeLink := func(src STATE, event EVENT, dst STATE, color string) {
+		buf.WriteString(fmt.Sprintf("    %s --> |%v| %s\n", ids[src], event, ids[dst]))
+		if color != "" {
+			styles = append(styles, fmt.Sprintf("    linkStyle %d stroke:%s", link, color))
+		}
+		link++
+	}
+	for _, e := range d.edges {
+		switch {
+		case !e.inNew:
+			writeLink(e.src, e.event, e.oldDst, diffRemovedColor)
+		case !e.inOld:
+			writeLink(e.src, e.event, e.newDst, diffAddedColor)
+		case e.oldDst != e.newDst:
+			writeLink(e.src, e.event, e.oldDst, diffRemovedColor)
+			writeLink(e.src, e.event, e.newDst, diffAddedColor)
+		default:
+			writeLink(e.src, e.event, e.newDst, "")
+		}
+	}
+	buf.WriteString("\n")
+
+	for _, s := range d.AddedStates {
+		buf.WriteString(fmt.Sprintf("    style %s fill:%s\n", ids[s], diffAddedColor))
+	}
+	for _, s := range d.RemovedStates {
+		buf.WriteString(fmt.Sprintf("    style %s fill:%s\n", ids[s], diffRemovedColor))
+	}
+	for _, style := range styles {
+		buf.WriteString(style)
+		buf.WriteString("\n")
+	}
+	return buf.String()
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsm
+
+import "testing"
+
+func TestDiff(t *testing.T) {
+	from := newDoorFSM()
+	to := NewFSM[string, string, door, any]("locked", []EventDesc[string, string]{
+		{Name: "open", Src: []string{"closed"}, Dst: "open"},
+		{Name: "close", Src: []string{"open"}, Dst: "locked"},
+		{Name: "unlock", Src: []string{"locked"}, Dst: "closed"},
+	})
+
+	d := Diff(from, to)
+	if d.Empty() {
+		t.Fatal("expected differences")
+	}
+	expected := `~ initial locked (was closed)
++ state locked
++ locked --unlock--> closed
+- closed --knock--> closed
+~ open --close--> locked (was closed)
+`
+	if got := d.String(); got != expected {
+		t.Errorf("unexpected text diff:\n%s", got)
+	}
+
+	expected = `graph LR
+    %% initial state changed from closed to locked
+    id0[closed]
+    id1[locked]
+    id2[open]
+
+    id0 --> |knock| id0
+    id0 --> |open| id2
+    id1 --> |unlock| id0
+    id2 --> |close| id0
+    id2 --> |close| id1
+
+    style id1 fill:#00AA00
+    linkStyle 0 stroke:#AA0000
+    linkStyle 2 stroke:#00AA00
+    linkStyle 3 stroke:#AA0000
+    linkStyle 4 stroke:#00AA00
+`
+	if got := d.Mermaid(); got != expected {
+		t.Errorf("unexpected mermaid diff:\n%s", got)
+	}
+
+	if d := Diff(from, newDoorFSM()); !d.Empty() || d.String() != "" {
+		t.Errorf("expected no difference, got %s", d)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsm
+
+import (
+	"context"
+	"errors"
+)
+
+// DriveTo moves the instance to the state target by firing, one at a time,
+// the events of a shortest path to it. Unlike SetState, every callback is
+// called along the way. The arguments of each event are given by argsFor,
+// which may be nil.
+//
+// When an event fails, for example because a callback canceled it, the
+// transition is avoided and a new path is computed from the current state.
+// The same happens when a transition leads somewhere unexpected; a transition
+// doing so twice is avoided as well.
+//
+// DriveTo returns the events of the transitions actually committed, along
+// with an UnreachableError if no path is left, or the error of ctx if it is
+// done before target is reached.
+func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) DriveTo(ctx context.Context, target STATE, argsFor func(EVENT) []ARG) ([]EVENT, error) {
+	taken := []EVENT{}
+	avoided := make(map[eKey[STATE, EVENT]]bool)
+	diverted := make(map[eKey[STATE, EVENT]]bool)
+	var last error
+	for {
+		s := f.load()
+		if s.state == target {
+			return taken, nil
+		}
+		if err := ctx.Err(); err != nil {
+			return taken, err
+		}
+		path := f.path(s.state, target, avoided)
+		if path == nil {
+			return taken, UnreachableError[STATE]{State: s.state, Target: target, Err: last, InstanceID: f.id, Phase: PhaseDispatch}
+		}
+
+		key := eKey[STATE, EVENT]{path[0], s.state}
+		var args []ARG
+		if argsFor != nil {
+			args = argsFor(key.event)
+		}
+		err := f.Event(key.event, args...)
+		now := f.load()
+		if err != nil && (now.version == s.version || now.state == s.state || errors.Is(err, ErrConflict)) {
+			// The transition did not happen, or was reverted, for example
+			// after a callback panicked under PanicRevert.
+			last = err
+			if !errors.Is(err, ErrConflict) {
+				avoided[key] = true
+			}
+			continue
+		}
+
+		taken = append(taken, key.event)
+		if now.state != f.transitions[key] {
+			avoided[key] = diverted[key]
+			diverted[key] = true
+		}
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsm
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDriveTo(t *testing.T) {
+	var args []any
+	inst := newOrderFSM().
+		Before("express", func(_ *door, e *Event[string, string, any]) { e.Cancel() }).
+		After("pay", func(_ *door, e *Event[string, string, any]) { args = e.Args }).
+		NewInstance()
+
+	taken, err := inst.DriveTo(context.Background(), "shipped", func(event string) []any {
+		if event == "pay" {
+			return []any{42}
+		}
+		return nil
+	})
+	if err != nil || inst.Current() != "shipped" {
+		t.Fatalf("expected to reach shipped, got %v in %s", err, inst.Current())
+	}
+	if !reflect.DeepEqual(taken, []string{"checkout", "pay", "ship"}) {
+		t.Errorf("expected to re-plan around express, got %v", taken)
+	}
+	if !reflect.DeepEqual(args, []any{42}) {
+		t.Errorf("expected pay to receive its arguments, got %v", args)
+	}
+
+	taken, err = inst.DriveTo(context.Background(), "shipped", nil)
+	if err != nil || len(taken) != 0 {
+		t.Errorf("expected nothing to do, got %v, %v", taken, err)
+	}
+}
+
+func TestDriveToUnreachable(t *testing.T) {
+	var inst *Instance[string, string, door, any]
+	inst = newOrderFSM().
+		Before("express", func(_ *door, e *Event[string, string, any]) { e.Cancel() }).
+		OnEnter("pending", func(_ *door, _ *Event[string, string, any]) { inst.SetState("cart") }).
+		NewInstance()
+
+	taken, err := inst.DriveTo(context.Background(), "paid", nil)
+	var unreachable UnreachableError[string]
+	if !errors.As(err, &unreachable) || unreachable.State != "cart" || unreachable.Target != "paid" || unreachable.Phase != PhaseDispatch {
+		t.Fatalf("expected UnreachableError, got %v", err)
+	}
+	if !errors.Is(err, ErrCanceled) {
+		t.Errorf("expected the last failure to be wrapped, got %v", err)
+	}
+	if !reflect.DeepEqual(taken, []string{"checkout", "checkout"}) {
+		t.Errorf("expected diverted transition to be tried twice, got %v", taken)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := newOrderFSM().NewInstance().DriveTo(ctx, "shipped", nil); err != context.Canceled {
+		t.Errorf("expected context error, got %v", err)
+	}
+}
+
+func TestDriveToRevertedPanic(t *testing.T) {
+	inst := NewFSM[string, string, door, any]("a", []EventDesc[string, string]{
+		{Name: "x", Src: []string{"a"}, Dst: "b"},
+		{Name: "y", Src: []string{"a"}, Dst: "c"},
+		{Name: "z", Src: []string{"c"}, Dst: "b"},
+	}).
+		SetPanicPolicy(PanicRevert).
+		OnEnter("b", func(_ *door, e *Event[string, string, any]) {
+			if e.Event == "x" {
+				panic("boom")
+			}
+		}).
+		NewInstance()
+
+	taken, err := inst.DriveTo(context.Background(), "b", nil)
+	if err != nil || inst.Current() != "b" {
+		t.Fatalf("expected to reach b, got %v in %s", err, inst.Current())
+	}
+	if !reflect.DeepEqual(taken, []string{"y", "z"}) {
+		t.Errorf("expected the reverted transition not to be taken, got %v", taken)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsm
+
+import "time"
+
+// DwellBounds are the upper bounds of the buckets of the dwell histograms.
+var DwellBounds = []time.Duration{
+	time.Second,
+	time.Minute,
+	10 * time.Minute,
+	time.Hour,
+	4 * time.Hour,
+	24 * time.Hour,
+	7 * 24 * time.Hour,
+}
+
+// EnteredAt returns the time the instance entered its current state. Changes
+// that keep the state, like SetState to the current state, do not reset it.
+func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) EnteredAt() time.Time {
+	return f.load().entered
+}
+
+// TimeInState returns the time spent in the current state so far.
+func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) TimeInState() time.Duration {
+	return f.clock.Now().Sub(f.load().entered)
+}
+
+// Dwell returns the cumulative time the instance spent in each state,
+// including the current stay.
+func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) Dwell() map[STATE]time.Duration {
+	s := f.load()
+	now := f.clock.Now()
+
+	f.dwellMu.Lock()
+	defer f.dwellMu.Unlock()
+	dwell := make(map[STATE]time.Duration, len(f.dwell)+1)
+	for state, d := range f.dwell {
+		dwell[state] = d
+	}
+	dwell[s.state] += now.Sub(s.entered)
+	return dwell
+}
+
+// addDwell records a completed stay in state on the instance and the model.
+func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) addDwell(state STATE, d time.Duration) {
+	f.dwellMu.Lock()
+	if f.dwell == nil {
+		f.dwell = make(map[STATE]time.Duration)
+	}
+	f.dwell[state] += d
+	f.dwellMu.Unlock()
+
+	f.FSM.dwellHistogram(state).Observe(d)
+}
+
+// DwellStats returns histograms of the completed stays of all instances of
+// the model in each state. The histograms keep being updated.
+func (f *FSM[STATE, EVENT, FSM_IMPL, ARG]) DwellStats() map[STATE]*Histogram {
+	f.dwellMu.Lock()
+	defer f.dwellMu.Unlock()
+	stats := make(map[STATE]*Histogram, len(f.dwell))
+	for state, h := range f.dwell {
+		stats[state] = h
+	}
+	return stats
+}
+
+func (f *FSM[STATE, EVENT, FSM_IMPL, ARG]) dwellHistogram(state STATE) *Histogram {
+	f.dwellMu.Lock()
+	defer f.dwellMu.Unlock()
+	h, ok := f.dwell[state]
+	if !ok {
+		h = NewHistogram(DwellBounds...)
+		f.dwell[state] = h
+	}
+	return h
+}
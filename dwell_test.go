@@ -0,0 +1,56 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsm_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/maozhixiang/fsm"
+	"github.com/maozhixiang/fsm/fsmtest"
+)
+
+func TestDwell(t *testing.T) {
+	clock := fsmtest.NewManualClock(epoch)
+	f := fsm.NewDoorFSM().SetClock(clock)
+	inst := f.NewInstance()
+	start := clock.Now()
+
+	clock.Advance(time.Minute)
+	inst.Event("open")
+	clock.Advance(time.Hour)
+	inst.SetState("open")
+	if !inst.EnteredAt().Equal(start.Add(time.Minute)) || inst.TimeInState() != time.Hour {
+		t.Errorf("expected open to be entered at %s for 1h, got %s for %s", start.Add(time.Minute), inst.EnteredAt(), inst.TimeInState())
+	}
+	inst.Event("close")
+	clock.Advance(2 * time.Minute)
+	inst.Event("open")
+	clock.Advance(time.Second)
+
+	dwell := inst.Dwell()
+	if dwell["closed"] != 3*time.Minute || dwell["open"] != time.Hour+time.Second {
+		t.Errorf("unexpected dwell %v", dwell)
+	}
+
+	f.NewInstance().Event("open")
+	stats := f.DwellStats()
+	if stats["closed"].Count() != 3 || stats["closed"].Sum() != 3*time.Minute {
+		t.Errorf("unexpected closed dwell stats %s", stats["closed"])
+	}
+	if stats["open"].Count() != 1 || stats["open"].Buckets()[3].Count != 1 {
+		t.Errorf("expected one stay of 1h in open, got %s", stats["open"])
+	}
+}
@@ -15,28 +15,64 @@
 
 package fsm
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors matching the error types of the same kind with errors.Is,
+// whatever their type parameters.
+var (
+	ErrInvalidEvent = errors.New("invalid event")
+	ErrUnknownEvent = errors.New("unknown event")
+	ErrNoTransition = errors.New("no transition")
+	ErrCanceled     = errors.New("transition canceled")
+	ErrConflict     = errors.New("conflicting state change")
+	ErrUnreachable  = errors.New("unreachable state")
+	ErrNoUndo       = errors.New("no transition to undo")
+	ErrIrreversible = errors.New("irreversible event")
+)
 
 // InvalidEventError is returned by FSM.Event() when the event cannot be called
 // in the current state.
 type InvalidEventError[STATE, EVENT comparable] struct {
 	Event EVENT
 	State STATE
+
+	// InstanceID is the ID of the instance the event was dispatched to.
+	InstanceID string
+	// Phase is the phase the dispatch stopped in, PhaseDispatch.
+	Phase Phase
 }
 
 func (e InvalidEventError[STATE, EVENT]) Error() string {
 	return fmt.Sprintf("event %v inappropriate in current state %v", e.Event, e.State)
 }
 
+// Is returns true for ErrInvalidEvent.
+func (e InvalidEventError[STATE, EVENT]) Is(target error) bool {
+	return target == ErrInvalidEvent
+}
+
 // UnknownEventError is returned by FSM.Event() when the event is not defined.
 type UnknownEventError[EVENT comparable] struct {
 	Event EVENT
+
+	// InstanceID is the ID of the instance the event was dispatched to.
+	InstanceID string
+	// Phase is the phase the dispatch stopped in, PhaseDispatch.
+	Phase Phase
 }
 
 func (e UnknownEventError[EVENT]) Error() string {
 	return fmt.Sprintf("event %v does not exist", e.Event)
 }
 
+// Is returns true for ErrUnknownEvent.
+func (e UnknownEventError[EVENT]) Is(target error) bool {
+	return target == ErrUnknownEvent
+}
+
 // InTransitionError is returned by FSM.Event() when an asynchronous transition
 // is already in progress.
 type InTransitionError[EVENT comparable] struct {
@@ -59,6 +95,11 @@ func (e NotInTransitionError) Error() string {
 // for example if the source and destination states are the same.
 type NoTransitionError struct {
 	Err error
+
+	// InstanceID is the ID of the instance the event was dispatched to.
+	InstanceID string
+	// Phase is the phase the dispatch stopped in, PhaseCommit.
+	Phase Phase
 }
 
 func (e NoTransitionError) Error() string {
@@ -68,10 +109,26 @@ func (e NoTransitionError) Error() string {
 	return "no transition"
 }
 
+// Unwrap returns the error set by the callbacks, if any.
+func (e NoTransitionError) Unwrap() error {
+	return e.Err
+}
+
+// Is returns true for ErrNoTransition.
+func (e NoTransitionError) Is(target error) bool {
+	return target == ErrNoTransition
+}
+
 // CanceledError is returned by FSM.Event() when a callback have canceled a
 // transition.
 type CanceledError struct {
 	Err error
+
+	// InstanceID is the ID of the instance the event was dispatched to.
+	InstanceID string
+	// Phase is the phase of the canceling callback, PhaseBefore or
+	// PhaseLeave.
+	Phase Phase
 }
 
 func (e CanceledError) Error() string {
@@ -81,6 +138,118 @@ func (e CanceledError) Error() string {
 	return "transition canceled"
 }
 
+// Unwrap returns the error given to Event.Cancel, if any.
+func (e CanceledError) Unwrap() error {
+	return e.Err
+}
+
+// Is returns true for ErrCanceled.
+func (e CanceledError) Is(target error) bool {
+	return target == ErrCanceled
+}
+
+// ConflictError is returned by Instance.Event() when the instance was changed
+// concurrently, for example by SetState, while the transition was in progress,
+// and by Instance.EventIfVersion() and Instance.EventFrom() when the instance
+// is not in the expected version or state.
+type ConflictError[STATE comparable] struct {
+	// ExpectedState and ExpectedVersion describe the state the transition
+	// started from.
+	ExpectedState   STATE
+	ExpectedVersion uint64
+
+	// State and Version describe the state the instance was found in.
+	State   STATE
+	Version uint64
+
+	// InstanceID is the ID of the instance the event was dispatched to.
+	InstanceID string
+	// Phase is the phase the dispatch stopped in, PhaseDispatch if the
+	// instance was found in the wrong state or version and PhaseCommit if it
+	// changed during the transition.
+	Phase Phase
+}
+
+func (e ConflictError[STATE]) Error() string {
+	return fmt.Sprintf("instance moved from state %v (version %d) to state %v (version %d)",
+		e.ExpectedState, e.ExpectedVersion, e.State, e.Version)
+}
+
+// Is returns true for ErrConflict.
+func (e ConflictError[STATE]) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// NoUndoError is returned by Instance.Undo() when the history holds no
+// transition leading to the current state.
+type NoUndoError struct {
+	// InstanceID is the ID of the instance the undo was requested for.
+	InstanceID string
+	// Phase is the phase the undo stopped in, PhaseDispatch.
+	Phase Phase
+}
+
+func (e NoUndoError) Error() string {
+	return "no transition to undo"
+}
+
+// Is returns true for ErrNoUndo.
+func (e NoUndoError) Is(target error) bool {
+	return target == ErrNoUndo
+}
+
+// IrreversibleError is returned by Instance.Undo() when the transition to undo
+// was performed by an irreversible event.
+type IrreversibleError[EVENT comparable] struct {
+	Event EVENT
+
+	// InstanceID is the ID of the instance the undo was requested for.
+	InstanceID string
+	// Phase is the phase the undo stopped in, PhaseDispatch.
+	Phase Phase
+}
+
+func (e IrreversibleError[EVENT]) Error() string {
+	return fmt.Sprintf("event %v is irreversible", e.Event)
+}
+
+// Is returns true for ErrIrreversible.
+func (e IrreversibleError[EVENT]) Is(target error) bool {
+	return target == ErrIrreversible
+}
+
+// UnreachableError is returned by Instance.DriveTo() when no sequence of
+// events leads from the current state to the target state.
+type UnreachableError[STATE comparable] struct {
+	State  STATE
+	Target STATE
+
+	// Err is the error of the last event that failed, if any.
+	Err error
+	// InstanceID is the ID of the driven instance.
+	InstanceID string
+	// Phase is the phase the drive stopped in, PhaseDispatch, since no event
+	// is left to dispatch.
+	Phase Phase
+}
+
+func (e UnreachableError[STATE]) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("state %v unreachable from state %v: %s", e.Target, e.State, e.Err)
+	}
+	return fmt.Sprintf("state %v unreachable from state %v", e.Target, e.State)
+}
+
+// Unwrap returns the error of the last event that failed, if any.
+func (e UnreachableError[STATE]) Unwrap() error {
+	return e.Err
+}
+
+// Is returns true for ErrUnreachable.
+func (e UnreachableError[STATE]) Is(target error) bool {
+	return target == ErrUnreachable
+}
+
 // InternalError is returned by FSM.Event() and should never occur. It is a
 // probably because of a bug.
 type InternalError struct{}
@@ -0,0 +1,78 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsm
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestErrorsIs(t *testing.T) {
+	locked := errors.New("locked")
+	inst := newDoorFSM().
+		OnLeave("open", func(_ *door, e *Event[string, string, any]) { e.Cancel(locked) }).
+		After("knock", func(_ *door, e *Event[string, string, any]) { e.Err = locked }).
+		NewInstance().
+		SetID("front")
+
+	err := inst.Event("knock")
+	if !errors.Is(err, ErrNoTransition) || !errors.Is(err, locked) {
+		t.Errorf("expected no transition wrapping the callback error, got %v", err)
+	}
+	if err := inst.Event("close"); !errors.Is(err, ErrInvalidEvent) || errors.Is(err, ErrUnknownEvent) {
+		t.Errorf("expected invalid event, got %v", err)
+	}
+	if err := inst.Event("lock"); !errors.Is(err, ErrUnknownEvent) {
+		t.Errorf("expected unknown event, got %v", err)
+	}
+	if err := inst.EventIfVersion(42, "open"); !errors.Is(err, ErrConflict) {
+		t.Errorf("expected conflict, got %v", err)
+	}
+
+	inst.Event("open")
+	err = fmt.Errorf("closing: %w", inst.Event("close"))
+	if !errors.Is(err, ErrCanceled) || !errors.Is(err, locked) {
+		t.Errorf("expected canceled transition wrapping the callback error, got %v", err)
+	}
+	var canceled CanceledError
+	if !errors.As(err, &canceled) || canceled.InstanceID != "front" || canceled.Phase != PhaseLeave {
+		t.Errorf("expected CanceledError of front in leave phase, got %+v", canceled)
+	}
+}
+
+func TestErrorFields(t *testing.T) {
+	inst := newDoorFSM().NewInstance().SetID("front")
+
+	var invalid InvalidEventError[string, string]
+	if err := inst.Event("close"); !errors.As(err, &invalid) || invalid.InstanceID != "front" || invalid.Phase != PhaseDispatch {
+		t.Errorf("unexpected invalid event error %+v", invalid)
+	}
+	var conflict ConflictError[string]
+	if err := inst.EventFrom("open", "open"); !errors.As(err, &conflict) || conflict.InstanceID != "front" || conflict.Phase != PhaseDispatch {
+		t.Errorf("unexpected conflict error %+v", conflict)
+	}
+	var noUndo NoUndoError
+	if err := inst.Undo(); !errors.As(err, &noUndo) || noUndo.InstanceID != "front" || noUndo.Phase != PhaseDispatch {
+		t.Errorf("unexpected no undo error %+v", noUndo)
+	}
+
+	inst = newDoorFSM().SetHistorySize(1).Irreversible("open").NewInstance().SetID("back")
+	inst.Event("open")
+	var irreversible IrreversibleError[string]
+	if err := inst.Undo(); !errors.As(err, &irreversible) || irreversible.InstanceID != "back" || irreversible.Phase != PhaseDispatch {
+		t.Errorf("unexpected irreversible error %+v", irreversible)
+	}
+}
@@ -32,8 +32,22 @@ type Event[STATE, EVENT comparable, ARG any] struct {
 	// Args is an optional list of arguments passed to the callback.
 	Args []ARG
 
+	// Undo is true if the callback is called by Instance.Undo, in which case
+	// Event is the event being undone.
+	Undo bool
+
+	// DryRun is true if the callback is called by Instance.Simulate, which
+	// only calls the callbacks marked with FSM.DryRunSafe.
+	DryRun bool
+
 	// canceled is an internal flag set if the transition is canceled.
 	canceled bool
+
+	// payload is the payload of an event fired with TypedEvent.Fire.
+	payload any
+
+	// trace reports the callbacks called for the event, if observed.
+	trace *trace[STATE, EVENT]
 }
 
 // Cancel can be called in before_<EVENT> or leave_<STATE> to cancel the
@@ -15,10 +15,20 @@
 
 package fsm
 
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
 // FSM is the state machine model that holds the transitions and callbacks.
 //
 // It has to be created with NewFSM to function properly.
 type FSM[STATE, EVENT comparable, FSM_IMPL, ARG any] struct {
+	// instanceCount is used to generate instance IDs. It is accessed
+	// atomically and kept first for 64-bit alignment.
+	instanceCount uint64
+
 	initial            STATE
 	fsmImplConstructor func() *FSM_IMPL
 
@@ -29,6 +39,36 @@ type FSM[STATE, EVENT comparable, FSM_IMPL, ARG any] struct {
 	eventCallbackFunc    map[EVENT]eventCallbackFunc[STATE, EVENT, FSM_IMPL, ARG]
 	allStateCallbackFunc stateCallbackFunc[STATE, EVENT, FSM_IMPL, ARG]
 	allEventCallbackFunc eventCallbackFunc[STATE, EVENT, FSM_IMPL, ARG]
+
+	// historySize is the default history size of new instances.
+	historySize int
+
+	// undoFunc maps events to the actions undoing them.
+	undoFunc map[EVENT]Callback[STATE, EVENT, FSM_IMPL, ARG]
+	// irreversible holds the events that cannot be undone.
+	irreversible map[EVENT]bool
+	// dryRunSafe holds the names of the callbacks Instance.Simulate may call.
+	dryRunSafe map[string]bool
+
+	// subscribers receive the transitions of all instances.
+	subscribers        subscribers[STATE, EVENT]
+	subscriptionPolicy SubscriptionPolicy
+	panicPolicy        PanicPolicy
+
+	// observers are notified of the phases of every dispatch.
+	observers []Observer[STATE, EVENT]
+	// logger records the transitions of instances without a logger.
+	logger Logger
+
+	// clock is the default clock of instances.
+	clock Clock
+	// dwellMu guards dwell.
+	dwellMu sync.Mutex
+	// dwell aggregates the time spent by instances in each state.
+	dwell map[STATE]*Histogram
+
+	// timeouts maps states to the events fired after a time in them.
+	timeouts map[STATE]timeout[EVENT]
 }
 
 // EventDesc represents an event when initializing the FSM.
@@ -64,6 +104,12 @@ func NewFSM[STATE, EVENT comparable, FSM_IMPL, ARG any](initial STATE, events []
 		transitions:       make(map[eKey[STATE, EVENT]]STATE),
 		stateCallbackFunc: make(map[STATE]stateCallbackFunc[STATE, EVENT, FSM_IMPL, ARG]),
 		eventCallbackFunc: make(map[EVENT]eventCallbackFunc[STATE, EVENT, FSM_IMPL, ARG]),
+		undoFunc:          make(map[EVENT]Callback[STATE, EVENT, FSM_IMPL, ARG]),
+		irreversible:      make(map[EVENT]bool),
+		dryRunSafe:        make(map[string]bool),
+		clock:             SystemClock,
+		dwell:             make(map[STATE]*Histogram),
+		timeouts:          make(map[STATE]timeout[EVENT]),
 	}
 	// Build transition map
 	for _, e := range events {
@@ -143,6 +189,29 @@ func (f *FSM[STATE, EVENT, FSM_IMPL, ARG]) SetFsmImplConstructor(fsmImplConstruc
 	return f
 }
 
+// SetHistorySize sets the number of transitions recorded by instances created
+// afterwards. A size of zero, the default, disables the history.
+func (f *FSM[STATE, EVENT, FSM_IMPL, ARG]) SetHistorySize(size int) *FSM[STATE, EVENT, FSM_IMPL, ARG] {
+	f.historySize = size
+	return f
+}
+
+// OnUndo sets the action that reverts transitions performed by event e when
+// Instance.Undo is called. It replaces the leave and enter callbacks otherwise
+// run by the undo, and may cancel it.
+func (f *FSM[STATE, EVENT, FSM_IMPL, ARG]) OnUndo(e EVENT, cb Callback[STATE, EVENT, FSM_IMPL, ARG]) *FSM[STATE, EVENT, FSM_IMPL, ARG] {
+	f.undoFunc[e] = cb
+	return f
+}
+
+// Irreversible marks events whose transitions cannot be undone.
+func (f *FSM[STATE, EVENT, FSM_IMPL, ARG]) Irreversible(events ...EVENT) *FSM[STATE, EVENT, FSM_IMPL, ARG] {
+	for _, e := range events {
+		f.irreversible[e] = true
+	}
+	return f
+}
+
 // Can returns true if event can occur in the current state.
 func (f *FSM[STATE, EVENT, FSM_IMPL, ARG]) Can(current STATE, event EVENT) bool {
 	_, ok := f.transitions[eKey[STATE, EVENT]{event, current}]
@@ -167,6 +236,83 @@ type eKey[STATE, EVENT comparable] struct {
 	src   STATE
 }
 
+// hook is a callback along with what it is named after, following the naming
+// of the legacy callbacks: before_<EVENT>, before_event, leave_<STATE>,
+// leave_state, enter_<STATE>, enter_state, after_<EVENT>, after_event and
+// undo_<EVENT>.
+type hook[STATE, EVENT comparable, FSM_IMPL, ARG any] struct {
+	prefix string
+	// target is the event or state of a named callback, or "event" or
+	// "state" for a general one.
+	target any
+	fn     Callback[STATE, EVENT, FSM_IMPL, ARG]
+}
+
+func (h hook[STATE, EVENT, FSM_IMPL, ARG]) name() string {
+	return fmt.Sprintf("%s_%v", h.prefix, h.target)
+}
+
+// hooks returns the hooks of the defined callbacks among named and general.
+func hooks[STATE, EVENT comparable, FSM_IMPL, ARG any](prefix string, target any, named Callback[STATE, EVENT, FSM_IMPL, ARG], generalTarget string, general Callback[STATE, EVENT, FSM_IMPL, ARG]) []hook[STATE, EVENT, FSM_IMPL, ARG] {
+	var hs []hook[STATE, EVENT, FSM_IMPL, ARG]
+	if named != nil {
+		hs = append(hs, hook[STATE, EVENT, FSM_IMPL, ARG]{prefix, target, named})
+	}
+	if general != nil {
+		hs = append(hs, hook[STATE, EVENT, FSM_IMPL, ARG]{prefix, generalTarget, general})
+	}
+	return hs
+}
+
+func (f *FSM[STATE, EVENT, FSM_IMPL, ARG]) beforeHooks(e EVENT) []hook[STATE, EVENT, FSM_IMPL, ARG] {
+	return hooks("before", e, f.eventCallbackFunc[e].before, "event", f.allEventCallbackFunc.before)
+}
+
+func (f *FSM[STATE, EVENT, FSM_IMPL, ARG]) leaveHooks(s STATE) []hook[STATE, EVENT, FSM_IMPL, ARG] {
+	return hooks("leave", s, f.stateCallbackFunc[s].leave, "state", f.allStateCallbackFunc.leave)
+}
+
+func (f *FSM[STATE, EVENT, FSM_IMPL, ARG]) enterHooks(s STATE) []hook[STATE, EVENT, FSM_IMPL, ARG] {
+	return hooks("enter", s, f.stateCallbackFunc[s].enter, "state", f.allStateCallbackFunc.enter)
+}
+
+func (f *FSM[STATE, EVENT, FSM_IMPL, ARG]) afterHooks(e EVENT) []hook[STATE, EVENT, FSM_IMPL, ARG] {
+	return hooks("after", e, f.eventCallbackFunc[e].after, "event", f.allEventCallbackFunc.after)
+}
+
+func (f *FSM[STATE, EVENT, FSM_IMPL, ARG]) undoHooks(e EVENT) []hook[STATE, EVENT, FSM_IMPL, ARG] {
+	return hooks[STATE, EVENT, FSM_IMPL, ARG]("undo", e, f.undoFunc[e], "", nil)
+}
+
+// Callbacks returns the names of the callbacks defined on the model, sorted:
+// before_<EVENT>, before_event, leave_<STATE>, leave_state, enter_<STATE>,
+// enter_state, after_<EVENT>, after_event and undo_<EVENT>.
+func (f *FSM[STATE, EVENT, FSM_IMPL, ARG]) Callbacks() []string {
+	var hs []hook[STATE, EVENT, FSM_IMPL, ARG]
+	for e, cb := range f.eventCallbackFunc {
+		hs = append(hs, hooks[STATE, EVENT, FSM_IMPL, ARG]("before", e, cb.before, "", nil)...)
+		hs = append(hs, hooks[STATE, EVENT, FSM_IMPL, ARG]("after", e, cb.after, "", nil)...)
+	}
+	for s, cb := range f.stateCallbackFunc {
+		hs = append(hs, hooks[STATE, EVENT, FSM_IMPL, ARG]("leave", s, cb.leave, "", nil)...)
+		hs = append(hs, hooks[STATE, EVENT, FSM_IMPL, ARG]("enter", s, cb.enter, "", nil)...)
+	}
+	for e := range f.undoFunc {
+		hs = append(hs, f.undoHooks(e)...)
+	}
+	hs = append(hs, hooks[STATE, EVENT, FSM_IMPL, ARG]("before", "event", f.allEventCallbackFunc.before, "", nil)...)
+	hs = append(hs, hooks[STATE, EVENT, FSM_IMPL, ARG]("after", "event", f.allEventCallbackFunc.after, "", nil)...)
+	hs = append(hs, hooks[STATE, EVENT, FSM_IMPL, ARG]("leave", "state", f.allStateCallbackFunc.leave, "", nil)...)
+	hs = append(hs, hooks[STATE, EVENT, FSM_IMPL, ARG]("enter", "state", f.allStateCallbackFunc.enter, "", nil)...)
+
+	names := make([]string, len(hs))
+	for i, h := range hs {
+		names[i] = h.name()
+	}
+	sort.Strings(names)
+	return names
+}
+
 type stateCallbackFunc[STATE, EVENT comparable, FSM_IMPL, ARG any] struct {
 	enter, leave Callback[STATE, EVENT, FSM_IMPL, ARG]
 }
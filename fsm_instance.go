@@ -15,19 +15,76 @@
 package fsm
 
 import (
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// Instance is a state machine created from an FSM model.
+//
+// The state is stored as an immutable snapshot that is replaced atomically, so
+// Current, Is, Can and AvailableTransitions never block, not even while a
+// transition is running. Transitions started by Event and Undo are serialized
+// per instance and commit their destination state with a compare-and-swap
+// against the snapshot they started from; SetState is applied immediately.
+//
+// Callbacks run without any state lock held. Before and leave callbacks
+// observe the source state as the current state, enter and after callbacks
+// observe the destination state. A callback must not call Event or Undo on its
+// own instance.
 type Instance[STATE, EVENT comparable, FSM_IMPL, ARG any] struct {
 	*FSM[STATE, EVENT, FSM_IMPL, ARG]
 	Self *FSM_IMPL
-	// current is the state that the FSM is currently in.
-	current STATE
 
-	// stateMu guards access to the current state.
-	stateMu sync.RWMutex
-	// eventMu guards access to Event() and Transition().
+	// id identifies the instance in transitions and subscriptions.
+	id string
+
+	// state holds the current *snapshot of the instance.
+	state atomic.Value
+	// eventMu serializes Event() and Undo().
 	eventMu sync.Mutex
+
+	// history records the most recent transitions, if enabled.
+	history history[STATE, EVENT]
+	// subscribers receive the transitions of the instance.
+	subscribers subscribers[STATE, EVENT]
+	// logger records the transitions of the instance, if set.
+	logger Logger
+	// observers are notified of the dispatches to the instance, after the
+	// observers of the model.
+	observers []Observer[STATE, EVENT]
+	// clock provides the time to the instance.
+	clock Clock
+
+	// dwellMu guards dwell.
+	dwellMu sync.Mutex
+	// dwell is the time spent in each state, not counting the current stay.
+	dwell map[STATE]time.Duration
+
+	// timerMu guards timer and armed.
+	timerMu sync.Mutex
+	// timer fires the timeout of the stay of armed, if any.
+	timer Timer
+	armed *snapshot[STATE]
+}
+
+// snapshot is an immutable pair of a state and the version it was stored
+// with. The version is incremented on every state change.
+type snapshot[STATE comparable] struct {
+	state   STATE
+	version uint64
+	// entered is the time the instance entered state.
+	entered time.Time
+	// stay counts the state changes of the instance, it is kept by changes
+	// to the same state.
+	stay uint64
+	// replaced is closed once the snapshot is replaced by another one.
+	replaced chan struct{}
+}
+
+func newSnapshot[STATE comparable](state STATE, version uint64, entered time.Time) *snapshot[STATE] {
+	return &snapshot[STATE]{state: state, version: version, entered: entered, replaced: make(chan struct{})}
 }
 
 func (f *FSM[STATE, EVENT, FSM_IMPL, ARG]) NewInstance() *Instance[STATE, EVENT, FSM_IMPL, ARG] {
@@ -37,56 +94,120 @@ func (f *FSM[STATE, EVENT, FSM_IMPL, ARG]) NewInstance() *Instance[STATE, EVENT,
 	} else {
 		impl = new(FSM_IMPL)
 	}
-	return &Instance[STATE, EVENT, FSM_IMPL, ARG]{
-		FSM:     f,
-		Self:    impl,
-		current: f.initial,
-	}
+	return f.NewInstanceWithImpl(impl)
 }
 func (f *FSM[STATE, EVENT, FSM_IMPL, ARG]) NewInstanceWithImpl(impl *FSM_IMPL) *Instance[STATE, EVENT, FSM_IMPL, ARG] {
-	return &Instance[STATE, EVENT, FSM_IMPL, ARG]{
-		FSM:     f,
-		Self:    impl,
-		current: f.initial,
+	inst := &Instance[STATE, EVENT, FSM_IMPL, ARG]{
+		FSM:   f,
+		Self:  impl,
+		id:    strconv.FormatUint(atomic.AddUint64(&f.instanceCount, 1), 10),
+		clock: f.clock,
+	}
+	inst.state.Store(newSnapshot(f.initial, 0, inst.clock.Now()))
+	inst.history.setSize(f.historySize)
+	inst.rearm()
+	return inst
+}
+
+// ID returns the ID of the instance. Unless set with SetID, it is a sequence
+// number unique within the model.
+func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) ID() string {
+	return f.id
+}
+
+// SetID sets the ID of the instance, typically to the key it is persisted
+// with. It must be called before the instance is shared.
+func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) SetID(id string) *Instance[STATE, EVENT, FSM_IMPL, ARG] {
+	f.id = id
+	return f
+}
+
+// SetHistorySize enables the transition history of the instance, keeping at
+// most size transitions. A size of zero disables the history.
+func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) SetHistorySize(size int) *Instance[STATE, EVENT, FSM_IMPL, ARG] {
+	f.history.setSize(size)
+	return f
+}
+
+// History returns the recorded transitions of the instance, oldest first.
+// Every call to Event is recorded, including rejected and canceled ones.
+func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) History() []Transition[STATE, EVENT] {
+	return f.history.list()
+}
+
+// load returns the current snapshot.
+func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) load() *snapshot[STATE] {
+	return f.state.Load().(*snapshot[STATE])
+}
+
+// commit moves the instance from the snapshot s to state dst and returns the
+// new snapshot. It returns nil if the instance was changed since s was loaded.
+func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) commit(s *snapshot[STATE], dst STATE) *snapshot[STATE] {
+	return f.swap(s, dst, s.version+1)
+}
+
+// swap replaces the snapshot old by a snapshot of state and version, wakes up
+// the waiters of old and records the time spent in the state left. It returns
+// the new snapshot, or nil if old was not current.
+func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) swap(old *snapshot[STATE], state STATE, version uint64) *snapshot[STATE] {
+	now := f.clock.Now()
+	new := newSnapshot(state, version, now)
+	new.stay = old.stay + 1
+	if state == old.state {
+		new.entered, new.stay = old.entered, old.stay
 	}
+	if !f.state.CompareAndSwap(old, new) {
+		return nil
+	}
+	close(old.replaced)
+	if state != old.state {
+		f.addDwell(old.state, now.Sub(old.entered))
+		f.rearm()
+	}
+	return new
 }
 
 // Current returns the current state of the FSM.
 func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) Current() STATE {
-	f.stateMu.RLock()
-	defer f.stateMu.RUnlock()
-	return f.current
+	return f.load().state
 }
 
 // Is returns true if state is the current state.
 func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) Is(state STATE) bool {
-	f.stateMu.RLock()
-	defer f.stateMu.RUnlock()
-	return state == f.current
+	return state == f.load().state
+}
+
+// Version returns the version of the instance. It starts at zero and is
+// incremented on every state change, including the ones made by SetState.
+func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) Version() uint64 {
+	return f.load().version
+}
+
+// Restore sets the state and version of the instance, typically to the values
+// persisted along with it. The call does not trigger any callbacks, if
+// defined. A transition in progress will fail to commit with a ConflictError.
+func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) Restore(state STATE, version uint64) {
+	for f.swap(f.load(), state, version) == nil {
+	}
 }
 
 // SetState allows the user to move to the given state from current state.
-// The call does not trigger any callbacks, if defined.
+// The call does not trigger any callbacks, if defined. A transition in
+// progress will fail to commit with a ConflictError.
 func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) SetState(state STATE) {
-	f.stateMu.Lock()
-	defer f.stateMu.Unlock()
-	f.current = state
-	return
+	for f.commit(f.load(), state) == nil {
+	}
 }
 
 // Can returns true if event can occur in the current state.
 func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) Can(event EVENT) bool {
-	f.stateMu.RLock()
-	defer f.stateMu.RUnlock()
-	return f.FSM.Can(f.current, event)
+	return f.FSM.Can(f.load().state, event)
 }
 
 // AvailableTransitions returns a list of transitions available in the
 // current state.
 func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) AvailableTransitions() []EVENT {
-	f.stateMu.RLock()
-	defer f.stateMu.RUnlock()
-	return f.FSM.AvailableTransitions(f.current)
+	return f.FSM.AvailableTransitions(f.load().state)
 }
 
 // Cannot returns true if event can not occur in the current state.
@@ -106,100 +227,261 @@ func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) Cannot(event EVENT) bool {
 //
 // - event X does not exist
 //
+// - instance moved from state X to state Y, if the state was changed by
+// SetState while the transition was in progress
+//
+// - callback X panicked, if the PanicPolicy of the model recovers panics
 func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) Event(event EVENT, args ...ARG) error {
+	return f.dispatch(nil, event, args, nil)
+}
+
+// EventIfVersion is like Event but fails with a ConflictError if the version
+// of the instance is not expected.
+func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) EventIfVersion(expected uint64, event EVENT, args ...ARG) error {
+	return f.dispatch(func(s *snapshot[STATE]) error {
+		if s.version != expected {
+			return ConflictError[STATE]{
+				ExpectedState:   s.state,
+				ExpectedVersion: expected,
+				State:           s.state,
+				Version:         s.version,
+				InstanceID:      f.id,
+				Phase:           PhaseDispatch,
+			}
+		}
+		return nil
+	}, event, args, nil)
+}
+
+// EventFrom is like Event but fails with a ConflictError if the instance is
+// not in the expected state.
+func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) EventFrom(expected STATE, event EVENT, args ...ARG) error {
+	return f.dispatch(func(s *snapshot[STATE]) error {
+		if s.state != expected {
+			return ConflictError[STATE]{
+				ExpectedState:   expected,
+				ExpectedVersion: s.version,
+				State:           s.state,
+				Version:         s.version,
+				InstanceID:      f.id,
+				Phase:           PhaseDispatch,
+			}
+		}
+		return nil
+	}, event, args, nil)
+}
+
+// dispatch performs and records a transition if the precondition, if any,
+// holds for the current snapshot. The payload, if any, is passed to typed
+// callbacks.
+func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) dispatch(precondition func(*snapshot[STATE]) error, event EVENT, args []ARG, payload any) error {
 	f.eventMu.Lock()
 	defer f.eventMu.Unlock()
 
-	f.stateMu.RLock()
-	defer f.stateMu.RUnlock()
+	s := f.load()
+	t := Transition[STATE, EVENT]{InstanceID: f.id, Event: event, Src: s.state, Dst: s.state, Time: f.clock.Now()}
+	observers := f.FSM.observers
+	if len(f.observers) > 0 {
+		observers = append(append([]Observer[STATE, EVENT](nil), observers...), f.observers...)
+	}
+	tr := newTrace(observers, f.clock, f.id, event, s.state)
+	if precondition != nil {
+		if t.Err = precondition(s); t.Err != nil {
+			tr.point(PhaseReject, t.Err)
+		}
+	}
+	if t.Err == nil {
+		t.Dst, t.Committed, t.Err = f.event(s, event, args, payload, tr)
+	}
+	tr.done(t.Err)
+	f.record(t)
+	f.log(t, args)
+	return t.Err
+}
 
-	dst, ok := f.transitions[eKey[STATE, EVENT]{event, f.current}]
+// record adds t to the history and publishes it to the subscribers if it was
+// committed.
+func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) record(t Transition[STATE, EVENT]) {
+	f.history.add(t)
+	if t.Committed {
+		f.subscribers.publish(t)
+		f.FSM.subscribers.publish(t)
+	}
+}
+
+// event performs the transition from snapshot s. It returns the destination
+// state, which is the source state if the event was rejected, and whether the
+// transition was committed.
+func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) event(s *snapshot[STATE], event EVENT, args []ARG, payload any, tr *trace[STATE, EVENT]) (dst STATE, committed bool, err error) {
+	var next *snapshot[STATE]
+	defer f.recoverCallback(s, &next, &committed, &err, tr)
+
+	dst, ok := f.transitions[eKey[STATE, EVENT]{event, s.state}]
 	if !ok {
-		for ekey := range f.transitions {
-			if ekey.event == event {
-				return InvalidEventError[STATE, EVENT]{event, f.current}
-			}
-		}
-		return UnknownEventError[EVENT]{event}
+		err = f.rejection(event, s.state)
+		tr.point(PhaseReject, err)
+		return s.state, false, err
 	}
 
-	e := &Event[STATE, EVENT, ARG]{event, f.current, dst, nil, args, false}
+	tr.o.Dst = dst
+	e := &Event[STATE, EVENT, ARG]{Event: event, Src: s.state, Dst: dst, Args: args, payload: payload, trace: tr}
 
-	err := f.beforeEventCallbacks(e)
+	err = f.beforeEventCallbacks(e)
+	tr.phase(PhaseBefore)
 	if err != nil {
-		return err
+		tr.point(PhaseCancel, err)
+		return dst, false, err
 	}
 
-	if f.current == dst {
+	if s.state == dst {
 		f.afterEventCallbacks(e)
-		return NoTransitionError{e.Err}
+		tr.phase(PhaseAfter)
+		return dst, false, NoTransitionError{Err: e.Err, InstanceID: f.id, Phase: PhaseCommit}
 	}
 
-	if err = f.leaveStateCallbacks(e); err != nil {
-		return err
+	err = f.leaveStateCallbacks(e)
+	tr.phase(PhaseLeave)
+	if err != nil {
+		tr.point(PhaseCancel, err)
+		return dst, false, err
 	}
 
-	f.current = dst
+	if next = f.commit(s, dst); next == nil {
+		err = f.conflict(s)
+		tr.point(PhaseReject, err)
+		return dst, false, err
+	}
+	tr.o.Committed = true
+	tr.phase(PhaseCommit)
 	f.enterStateCallbacks(e)
+	tr.phase(PhaseEnter)
 	f.afterEventCallbacks(e)
+	tr.phase(PhaseAfter)
 
-	return e.Err
+	return dst, true, e.Err
 }
 
-// beforeEventCallbacks calls the before_ callbacks, first the named then the
-// general version.
-func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) beforeEventCallbacks(e *Event[STATE, EVENT, ARG]) error {
-	if fn := f.eventCallbackFunc[e.Event].before; fn != nil {
-		fn(f.Self, e)
-		if e.canceled {
-			return CanceledError{e.Err}
+// rejection returns the error reported when event cannot be dispatched in
+// state.
+func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) rejection(event EVENT, state STATE) error {
+	for ekey := range f.transitions {
+		if ekey.event == event {
+			return InvalidEventError[STATE, EVENT]{Event: event, State: state, InstanceID: f.id, Phase: PhaseDispatch}
 		}
 	}
-	if fn := f.allEventCallbackFunc.before; fn != nil {
-		fn(f.Self, e)
-		if e.canceled {
-			return CanceledError{e.Err}
+	return UnknownEventError[EVENT]{Event: event, InstanceID: f.id, Phase: PhaseDispatch}
+}
+
+// conflict returns the error reported when a transition started from snapshot
+// s cannot be committed.
+func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) conflict(s *snapshot[STATE]) error {
+	actual := f.load()
+	return ConflictError[STATE]{
+		ExpectedState:   s.state,
+		ExpectedVersion: s.version,
+		State:           actual.state,
+		Version:         actual.version,
+		InstanceID:      f.id,
+		Phase:           PhaseCommit,
+	}
+}
+
+// Undo reverts the most recent transition recorded in the history that has
+// not been undone yet, moving the instance back to its source state.
+//
+// If an undo action is defined for the event with FSM.OnUndo, it is called
+// instead of the state callbacks. Otherwise the leave callbacks of the current
+// state and the enter callbacks of the previous state are called, with
+// Event.Undo set. Both may cancel the undo.
+//
+// It will return nil if the state change is ok or one of these errors:
+//
+// - no transition to undo, if the history is disabled or exhausted
+//
+// - event X is irreversible
+func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) Undo() error {
+	f.eventMu.Lock()
+	defer f.eventMu.Unlock()
+
+	s := f.load()
+	last, ok := f.history.lastUndoable()
+	if !ok || last.Dst != s.state {
+		return NoUndoError{InstanceID: f.id, Phase: PhaseDispatch}
+	}
+	if f.irreversible[last.Event] {
+		return IrreversibleError[EVENT]{Event: last.Event, InstanceID: f.id, Phase: PhaseDispatch}
+	}
+
+	e := &Event[STATE, EVENT, ARG]{Event: last.Event, Src: s.state, Dst: last.Src, Undo: true}
+	t := Transition[STATE, EVENT]{InstanceID: f.id, Event: last.Event, Src: e.Src, Dst: e.Dst, Time: f.clock.Now(), Undo: true}
+	t.Committed, t.Err = f.undo(s, e, nil)
+	f.record(t)
+	f.log(t, nil)
+	return t.Err
+}
+
+func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) undo(s *snapshot[STATE], e *Event[STATE, EVENT, ARG], tr *trace[STATE, EVENT]) (committed bool, err error) {
+	var next *snapshot[STATE]
+	defer f.recoverCallback(s, &next, &committed, &err, tr)
+
+	if hooks := f.undoHooks(e.Event); len(hooks) > 0 {
+		if _, err := f.callCancelable(hooks, e, PhaseLeave); err != nil {
+			return false, err
 		}
+		if next = f.commit(s, e.Dst); next == nil {
+			return false, f.conflict(s)
+		}
+		return true, e.Err
+	}
+
+	if err := f.leaveStateCallbacks(e); err != nil {
+		return false, err
 	}
-	return nil
+	if next = f.commit(s, e.Dst); next == nil {
+		return false, f.conflict(s)
+	}
+	f.enterStateCallbacks(e)
+	return true, e.Err
+}
+
+// beforeEventCallbacks calls the before_ callbacks, first the named then the
+// general version.
+func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) beforeEventCallbacks(e *Event[STATE, EVENT, ARG]) error {
+	_, err := f.callCancelable(f.beforeHooks(e.Event), e, PhaseBefore)
+	return err
 }
 
 // leaveStateCallbacks calls the leave_ callbacks, first the named then the
 // general version.
 func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) leaveStateCallbacks(e *Event[STATE, EVENT, ARG]) error {
-	if fn := f.stateCallbackFunc[f.current].leave; fn != nil {
-		fn(f.Self, e)
-		if e.canceled {
-			return CanceledError{e.Err}
-		}
-	}
-	if fn := f.allStateCallbackFunc.leave; fn != nil {
-		fn(f.Self, e)
-		if e.canceled {
-			return CanceledError{e.Err}
-		}
-	}
-	return nil
+	_, err := f.callCancelable(f.leaveHooks(e.Src), e, PhaseLeave)
+	return err
 }
 
 // enterStateCallbacks calls the enter_ callbacks, first the named then the
 // general version.
 func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) enterStateCallbacks(e *Event[STATE, EVENT, ARG]) {
-	if fn := f.stateCallbackFunc[f.current].enter; fn != nil {
-		fn(f.Self, e)
-	}
-	if fn := f.allStateCallbackFunc.enter; fn != nil {
-		fn(f.Self, e)
+	for _, h := range f.enterHooks(e.Dst) {
+		f.callHook(h, e, PhaseEnter)
 	}
 }
 
 // afterEventCallbacks calls the after_ callbacks, first the named then the
 // general version.
 func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) afterEventCallbacks(e *Event[STATE, EVENT, ARG]) {
-	if fn := f.eventCallbackFunc[e.Event].after; fn != nil {
-		fn(f.Self, e)
+	for _, h := range f.afterHooks(e.Event) {
+		f.callHook(h, e, PhaseAfter)
 	}
-	if fn := f.allEventCallbackFunc.after; fn != nil {
-		fn(f.Self, e)
+}
+
+// callCancelable calls the hooks of phase in order until one of them cancels
+// e. It returns the name of that hook along with a CanceledError.
+func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) callCancelable(hooks []hook[STATE, EVENT, FSM_IMPL, ARG], e *Event[STATE, EVENT, ARG], phase Phase) (string, error) {
+	for _, h := range hooks {
+		f.callHook(h, e, phase)
+		if e.canceled {
+			return h.name(), CanceledError{Err: e.Err, InstanceID: f.id, Phase: phase}
+		}
 	}
+	return "", nil
 }
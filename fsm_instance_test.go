@@ -0,0 +1,158 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsm
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestCallbacksObserveState(t *testing.T) {
+	var inst *Instance[string, string, door, any]
+	expect := func(state string) Callback[string, string, door, any] {
+		return func(_ *door, e *Event[string, string, any]) {
+			if current := inst.Current(); current != state {
+				t.Errorf("expected callback to observe %s, got %s", state, current)
+			}
+		}
+	}
+	inst = newDoorFSM().
+		Before("open", expect("closed")).
+		OnLeave("closed", expect("closed")).
+		OnEnter("open", expect("open")).
+		After("open", expect("open")).
+		NewInstance()
+	if err := inst.Event("open"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadsDoNotBlockOnTransition(t *testing.T) {
+	release := make(chan struct{})
+	entered := make(chan struct{})
+	inst := newDoorFSM().
+		OnLeave("closed", func(_ *door, _ *Event[string, string, any]) {
+			close(entered)
+			<-release
+		}).
+		NewInstance()
+
+	done := make(chan error)
+	go func() { done <- inst.Event("open") }()
+	<-entered
+	if !inst.Is("closed") || !inst.Can("open") || len(inst.AvailableTransitions()) != 2 {
+		t.Error("expected reads to observe the source state during the transition")
+	}
+	close(release)
+	if err := <-done; err != nil || inst.Current() != "open" {
+		t.Errorf("expected transition to open, got %v in %s", err, inst.Current())
+	}
+}
+
+func TestSetStateDuringTransitionConflicts(t *testing.T) {
+	var inst *Instance[string, string, door, any]
+	inst = newDoorFSM().
+		OnLeave("closed", func(_ *door, _ *Event[string, string, any]) { inst.SetState("open") }).
+		SetHistorySize(1).
+		NewInstance()
+
+	err := inst.Event("open")
+	conflict, ok := err.(ConflictError[string])
+	if !ok {
+		t.Fatalf("expected ConflictError, got %v", err)
+	}
+	if conflict.ExpectedState != "closed" || conflict.State != "open" || conflict.Version != conflict.ExpectedVersion+1 {
+		t.Errorf("unexpected conflict %+v", conflict)
+	}
+	if h := inst.History(); h[0].Committed {
+		t.Error("expected conflicting transition not to be committed")
+	}
+}
+
+func TestConcurrentEventSetStateCurrent(t *testing.T) {
+	inst := newDoorFSM().SetHistorySize(16).NewInstance()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				inst.Event("open")
+				inst.Event("close")
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				inst.SetState("closed")
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				if s := inst.Current(); s != "open" && s != "closed" {
+					t.Errorf("unexpected state %s", s)
+				}
+				inst.Can("open")
+				inst.History()
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, tr := range inst.History() {
+		if _, ok := tr.Err.(ConflictError[string]); ok && tr.Committed {
+			t.Errorf("conflicting transition recorded as committed: %+v", tr)
+		}
+	}
+}
+
+func TestVersionedEvents(t *testing.T) {
+	inst := newDoorFSM().NewInstance()
+	if inst.Version() != 0 {
+		t.Errorf("expected initial version 0, got %d", inst.Version())
+	}
+	inst.Event("open")
+	inst.SetState("closed")
+	if inst.Version() != 2 {
+		t.Errorf("expected version 2, got %d", inst.Version())
+	}
+
+	// A replica applied an event after the row was loaded.
+	stale := inst.Version()
+	inst.Event("open")
+	err := inst.EventIfVersion(stale, "close")
+	if conflict, ok := err.(ConflictError[string]); !ok || conflict.ExpectedVersion != stale || conflict.Version != 3 {
+		t.Errorf("expected version conflict, got %v", err)
+	}
+	if err := inst.EventIfVersion(inst.Version(), "close"); err != nil || inst.Current() != "closed" {
+		t.Errorf("expected transition to closed, got %v in %s", err, inst.Current())
+	}
+
+	err = inst.EventFrom("open", "open")
+	if conflict, ok := err.(ConflictError[string]); !ok || conflict.ExpectedState != "open" || conflict.State != "closed" {
+		t.Errorf("expected state conflict, got %v", err)
+	}
+	if err := inst.EventFrom("closed", "open"); err != nil || inst.Current() != "open" {
+		t.Errorf("expected transition to open, got %v in %s", err, inst.Current())
+	}
+
+	restored := newDoorFSM().NewInstance()
+	restored.Restore("open", 42)
+	if restored.Current() != "open" || restored.Version() != 42 {
+		t.Errorf("expected restored state open at version 42, got %s at %d", restored.Current(), restored.Version())
+	}
+}
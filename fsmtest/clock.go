@@ -0,0 +1,146 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fsmtest provides utilities for testing state machines built with
+// the fsm package.
+package fsmtest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/maozhixiang/fsm"
+)
+
+// ManualClock is an fsm.Clock whose time only moves when advanced, so that
+// timed workflows can be tested instantly and deterministically.
+//
+// Timers fire synchronously from Advance and Set, in the order of their
+// deadlines. A timer scheduled with a duration of zero or less fires on the
+// next call to either, Advance(0) included. Functions scheduled with
+// AfterFunc therefore run in the goroutine advancing the clock, which must
+// not hold any lock they need.
+type ManualClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*manualTimer
+}
+
+type manualTimer struct {
+	clock *ManualClock
+	at    time.Time
+	c     chan time.Time
+	f     func()
+}
+
+// NewManualClock creates a clock set to start.
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{now: start}
+}
+
+// Now implements fsm.Clock.
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTimer implements fsm.Clock.
+func (c *ManualClock) NewTimer(d time.Duration) fsm.Timer {
+	return c.schedule(&manualTimer{clock: c, c: make(chan time.Time, 1)}, d)
+}
+
+// AfterFunc implements fsm.Clock.
+func (c *ManualClock) AfterFunc(d time.Duration, f func()) fsm.Timer {
+	return c.schedule(&manualTimer{clock: c, f: f}, d)
+}
+
+// Advance moves the clock forward by d and fires the timers that expire.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.Set(c.Now().Add(d))
+}
+
+// Set moves the clock to t and fires the timers that expire. Setting the
+// clock back in time fires nothing.
+func (c *ManualClock) Set(t time.Time) {
+	c.mu.Lock()
+	if t.After(c.now) {
+		c.now = t
+	}
+	var expired []*manualTimer
+	pending := c.timers[:0]
+	for _, timer := range c.timers {
+		if timer.at.After(c.now) {
+			pending = append(pending, timer)
+		} else {
+			expired = append(expired, timer)
+		}
+	}
+	c.timers = pending
+	c.mu.Unlock()
+
+	sort.SliceStable(expired, func(i, j int) bool { return expired[i].at.Before(expired[j].at) })
+	for _, timer := range expired {
+		timer.fire()
+	}
+}
+
+// Pending returns the number of timers waiting to fire.
+func (c *ManualClock) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+func (c *ManualClock) schedule(t *manualTimer, d time.Duration) *manualTimer {
+	c.mu.Lock()
+	t.at = c.now.Add(d)
+	c.timers = append(c.timers, t)
+	c.mu.Unlock()
+	return t
+}
+
+func (t *manualTimer) fire() {
+	if t.f != nil {
+		t.f()
+		return
+	}
+	select {
+	case t.c <- t.at:
+	default:
+	}
+}
+
+func (t *manualTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *manualTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	for i, timer := range t.clock.timers {
+		if timer == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (t *manualTimer) Reset(d time.Duration) bool {
+	active := t.Stop()
+	t.clock.schedule(t, d)
+	return active
+}
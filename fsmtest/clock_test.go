@@ -0,0 +1,57 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsmtest
+
+import (
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestManualClock(t *testing.T) {
+	c := NewManualClock(epoch)
+	var fired []string
+	c.AfterFunc(2*time.Second, func() { fired = append(fired, "second") })
+	c.AfterFunc(time.Second, func() { fired = append(fired, "first") })
+	stopped := c.AfterFunc(time.Second, func() { fired = append(fired, "stopped") })
+	timer := c.NewTimer(3 * time.Second)
+
+	if !stopped.Stop() || stopped.Stop() {
+		t.Error("expected Stop to report whether the timer was active")
+	}
+	c.Advance(2 * time.Second)
+	if len(fired) != 2 || fired[0] != "first" || fired[1] != "second" {
+		t.Errorf("expected timers to fire in deadline order, got %v", fired)
+	}
+	select {
+	case <-timer.C():
+		t.Error("expected timer not to fire yet")
+	default:
+	}
+
+	timer.Reset(2 * time.Second)
+	c.Advance(time.Second)
+	if c.Pending() != 1 {
+		t.Errorf("expected reset timer to be pending, got %d timers", c.Pending())
+	}
+	c.Advance(time.Second)
+	if at := <-timer.C(); !at.Equal(epoch.Add(4 * time.Second)) {
+		t.Errorf("expected timer to fire at 4s, got %s", at.Sub(epoch))
+	}
+	if !c.Now().Equal(epoch.Add(4 * time.Second)) {
+		t.Errorf("unexpected time %s", c.Now())
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsmtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/maozhixiang/fsm"
+)
+
+const (
+	coveredColor   = "#00AA00"
+	uncoveredColor = "#AA0000"
+)
+
+// Coverage records which transitions and callbacks of a model are exercised,
+// typically across the tests of a package:
+//
+//	var coverage = fsmtest.NewCoverage(model)
+//
+//	func TestMain(m *testing.M) {
+//		code := m.Run()
+//		fmt.Print(coverage)
+//		os.Exit(code)
+//	}
+//
+// A transition is covered when it is committed, or when it is a self
+// transition reached without being canceled. A callback is covered when it
+// returns. Undo is not recorded.
+type Coverage[STATE, EVENT comparable] struct {
+	edges     []fsm.Edge[STATE, EVENT]
+	callbacks []string
+	visualize func(fsm.VisualizeType, STATE, map[fsm.Edge[STATE, EVENT]]string) (string, error)
+
+	mu           sync.Mutex
+	edgeHits     map[fsm.Edge[STATE, EVENT]]int
+	callbackHits map[string]int
+}
+
+// EdgeCoverage is the number of times a transition was exercised.
+type EdgeCoverage[STATE, EVENT comparable] struct {
+	Src   STATE `json:"src"`
+	Event EVENT `json:"event"`
+	Dst   STATE `json:"dst"`
+	Count int   `json:"count"`
+}
+
+// CallbackCoverage is the number of times a callback was exercised.
+type CallbackCoverage struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// NewCoverage creates a Coverage and adds it to the observers of model. The
+// transitions and callbacks defined on model afterwards are not reported.
+func NewCoverage[STATE, EVENT comparable, FSM_IMPL, ARG any](model *fsm.FSM[STATE, EVENT, FSM_IMPL, ARG]) *Coverage[STATE, EVENT] {
+	c := &Coverage[STATE, EVENT]{
+		edges:        model.Edges(),
+		callbacks:    model.Callbacks(),
+		visualize:    model.VisualizeHighlighted,
+		edgeHits:     make(map[fsm.Edge[STATE, EVENT]]int),
+		callbackHits: make(map[string]int),
+	}
+	model.AddObserver(c)
+	return c
+}
+
+// Observe implements fsm.Observer.
+func (c *Coverage[STATE, EVENT]) Observe(o fsm.Observation[STATE, EVENT]) {
+	switch {
+	case o.Phase == fsm.PhaseCallback:
+		c.mu.Lock()
+		c.callbackHits[o.Callback]++
+		c.mu.Unlock()
+	case o.Phase == fsm.PhaseDone && (o.Committed || errors.Is(o.Err, fsm.ErrNoTransition)):
+		c.mu.Lock()
+		c.edgeHits[fsm.Edge[STATE, EVENT]{Src: o.Src, Event: o.Event, Dst: o.Dst}]++
+		c.mu.Unlock()
+	}
+}
+
+// Edges returns the transitions of the model, sorted by source state then
+// event, with the number of times they were exercised.
+func (c *Coverage[STATE, EVENT]) Edges() []EdgeCoverage[STATE, EVENT] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	edges := make([]EdgeCoverage[STATE, EVENT], len(c.edges))
+	for i, e := range c.edges {
+		edges[i] = EdgeCoverage[STATE, EVENT]{Src: e.Src, Event: e.Event, Dst: e.Dst, Count: c.edgeHits[e]}
+	}
+	return edges
+}
+
+// Callbacks returns the callbacks of the model, sorted by name, with the
+// number of times they were exercised.
+func (c *Coverage[STATE, EVENT]) Callbacks() []CallbackCoverage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	callbacks := make([]CallbackCoverage, len(c.callbacks))
+	for i, name := range c.callbacks {
+		callbacks[i] = CallbackCoverage{Name: name, Count: c.callbackHits[name]}
+	}
+	return callbacks
+}
+
+// Uncovered returns the transitions never exercised.
+func (c *Coverage[STATE, EVENT]) Uncovered() []fsm.Edge[STATE, EVENT] {
+	var uncovered []fsm.Edge[STATE, EVENT]
+	for _, e := range c.Edges() {
+		if e.Count == 0 {
+			uncovered = append(uncovered, fsm.Edge[STATE, EVENT]{Src: e.Src, Event: e.Event, Dst: e.Dst})
+		}
+	}
+	return uncovered
+}
+
+// UncoveredCallbacks returns the names of the callbacks never exercised.
+func (c *Coverage[STATE, EVENT]) UncoveredCallbacks() []string {
+	var uncovered []string
+	for _, cb := range c.Callbacks() {
+		if cb.Count == 0 {
+			uncovered = append(uncovered, cb.Name)
+		}
+	}
+	return uncovered
+}
+
+// String outputs the report as text.
+func (c *Coverage[STATE, EVENT]) String() string {
+	var buf bytes.Buffer
+	edges, callbacks := c.Edges(), c.Callbacks()
+
+	covered := 0
+	for _, e := range edges {
+		if e.Count > 0 {
+			covered++
+		}
+	}
+	buf.WriteString(fmt.Sprintf("transitions: %s\n", ratio(covered, len(edges))))
+	for _, e := range edges {
+		buf.WriteString(fmt.Sprintf("  %s %v --%v--> %v\n", hits(e.Count), e.Src, e.Event, e.Dst))
+	}
+
+	covered = 0
+	for _, cb := range callbacks {
+		if cb.Count > 0 {
+			covered++
+		}
+	}
+	buf.WriteString(fmt.Sprintf("callbacks: %s\n", ratio(covered, len(callbacks))))
+	for _, cb := range callbacks {
+		buf.WriteString(fmt.Sprintf("  %s %s\n", hits(cb.Count), cb.Name))
+	}
+	return buf.String()
+}
+
+func ratio(covered, total int) string {
+	if total == 0 {
+		return "0/0"
+	}
+	return fmt.Sprintf("%d/%d (%.1f%%)", covered, total, 100*float64(covered)/float64(total))
+}
+
+func hits(count int) string {
+	if count == 0 {
+		return "uncovered   "
+	}
+	return fmt.Sprintf("covered %4d", count)
+}
+
+// MarshalJSON outputs the report as JSON, an object with the transitions and
+// callbacks as returned by Edges and Callbacks.
+func (c *Coverage[STATE, EVENT]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Transitions []EdgeCoverage[STATE, EVENT] `json:"transitions"`
+		Callbacks   []CallbackCoverage           `json:"callbacks"`
+	}{c.Edges(), c.Callbacks()})
+}
+
+// Visualize outputs the model as fsm.FSM.VisualizeHighlighted does, with the
+// covered transitions in green and the others in red.
+func (c *Coverage[STATE, EVENT]) Visualize(visualizeType fsm.VisualizeType, current STATE) (string, error) {
+	highlight := make(map[fsm.Edge[STATE, EVENT]]string)
+	for _, e := range c.Edges() {
+		color := coveredColor
+		if e.Count == 0 {
+			color = uncoveredColor
+		}
+		highlight[fsm.Edge[STATE, EVENT]{Src: e.Src, Event: e.Event, Dst: e.Dst}] = color
+	}
+	return c.visualize(visualizeType, current, highlight)
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsmtest
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/maozhixiang/fsm"
+)
+
+type door struct{}
+
+func newDoorFSM() *fsm.FSM[string, string, door, any] {
+	return fsm.NewFSM[string, string, door, any]("closed", []fsm.EventDesc[string, string]{
+		{Name: "open", Src: []string{"closed"}, Dst: "open"},
+		{Name: "close", Src: []string{"open"}, Dst: "closed"},
+		{Name: "knock", Src: []string{"closed"}, Dst: "closed"},
+	})
+}
+
+func TestCoverage(t *testing.T) {
+	noop := func(_ *door, _ *fsm.Event[string, string, any]) {}
+	model := newDoorFSM().
+		OnEnter("open", noop).
+		Before("close", func(_ *door, e *fsm.Event[string, string, any]) { e.Cancel() }).
+		AfterAny(noop)
+	coverage := NewCoverage(model)
+
+	inst := model.NewInstance()
+	inst.Event("knock")
+	inst.Event("open")
+	inst.Event("close")
+
+	expected := `transitions: 2/3 (66.7%)
+  covered    1 closed --knock--> closed
+  covered    1 closed --open--> open
+  uncovered    open --close--> closed
+callbacks: 3/3 (100.0%)
+  covered    2 after_event
+  covered    1 before_close
+  covered    1 enter_open
+`
+	if got := coverage.String(); got != expected {
+		t.Errorf("unexpected report:\n%s", got)
+	}
+	if uncovered := coverage.Uncovered(); len(uncovered) != 1 || uncovered[0].Event != "close" {
+		t.Errorf("expected close to be uncovered, got %v", uncovered)
+	}
+	if uncovered := coverage.UncoveredCallbacks(); len(uncovered) != 0 {
+		t.Errorf("expected all callbacks to be covered, got %v", uncovered)
+	}
+
+	var report struct {
+		Transitions []EdgeCoverage[string, string]
+		Callbacks   []CallbackCoverage
+	}
+	data, err := json.Marshal(coverage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &report); err != nil || len(report.Transitions) != 3 || report.Transitions[1].Count != 1 {
+		t.Errorf("unexpected JSON report %s", data)
+	}
+
+	diagram, err := coverage.Visualize(fsm.GRAPHVIZ, "closed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(diagram, `"open" -> "closed" [ label = "close" color = "#AA0000"`) ||
+		!strings.Contains(diagram, `"closed" -> "open" [ label = "open" color = "#00AA00"`) {
+		t.Errorf("unexpected diagram:\n%s", diagram)
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsmtest
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/maozhixiang/fsm"
+)
+
+// Scenario is a sequence of events dispatched to a fresh instance, with the
+// outcome expected after each of them.
+type Scenario[STATE, EVENT comparable, FSM_IMPL, ARG any] struct {
+	// Name names the subtest of the scenario.
+	Name string
+	// Start is the state set with SetState before the first step, if not
+	// nil. The instance starts in the initial state of the model otherwise.
+	Start *STATE
+	// Steps are dispatched in order. The scenario stops at the first step
+	// that does not go as expected.
+	Steps []Step[STATE, EVENT, ARG]
+	// Callbacks are the names of the callbacks expected to be called during
+	// the whole scenario, in order, like before_<EVENT> or enter_state. They
+	// are not checked if nil.
+	Callbacks []string
+}
+
+// Step is an event of a Scenario and its expected outcome.
+type Step[STATE, EVENT comparable, ARG any] struct {
+	Event EVENT
+	Args  []ARG
+	// State is the state expected after the event.
+	State STATE
+	// Err is the error expected from the event, nil for none. The error
+	// matches if errors.Is reports it does, so sentinels like
+	// fsm.ErrCanceled can be used, or if Err is the zero value of its type
+	// and the error has that type, so that fsm.InvalidEventError[S, E]{}
+	// matches any invalid event.
+	Err error
+}
+
+// RunScenarios runs each scenario as a subtest of t on a fresh instance of
+// model. Failures show the step, the expected and actual outcomes, and a
+// diff of the callbacks.
+func RunScenarios[STATE, EVENT comparable, FSM_IMPL, ARG any](t *testing.T, model *fsm.FSM[STATE, EVENT, FSM_IMPL, ARG], scenarios []Scenario[STATE, EVENT, FSM_IMPL, ARG]) {
+	t.Helper()
+	for _, s := range scenarios {
+		s := s
+		t.Run(s.Name, func(t *testing.T) {
+			t.Helper()
+			for _, failure := range runScenario(model, s) {
+				t.Error(failure)
+			}
+		})
+	}
+}
+
+// runScenario runs s and returns its failures.
+func runScenario[STATE, EVENT comparable, FSM_IMPL, ARG any](model *fsm.FSM[STATE, EVENT, FSM_IMPL, ARG], s Scenario[STATE, EVENT, FSM_IMPL, ARG]) []string {
+	r := &callbackRecorder[STATE, EVENT]{}
+	inst := model.NewInstance().AddObserver(r)
+	if s.Start != nil {
+		inst.SetState(*s.Start)
+	}
+
+	for i, step := range s.Steps {
+		src := inst.Current()
+		err := inst.Event(step.Event, step.Args...)
+		var mismatches []string
+		if state := inst.Current(); state != step.State {
+			mismatches = append(mismatches, fmt.Sprintf("state: got %v, want %v", state, step.State))
+		}
+		if !matchError(err, step.Err) {
+			mismatches = append(mismatches, fmt.Sprintf("error: got %s, want %s", describeError(err), describeError(step.Err)))
+		}
+		if len(mismatches) > 0 {
+			return []string{fmt.Sprintf("step %d: event %v from state %v:\n\t%s", i+1, step.Event, src, strings.Join(mismatches, "\n\t"))}
+		}
+	}
+
+	if s.Callbacks != nil {
+		if got := r.callbacks(); !reflect.DeepEqual(got, s.Callbacks) && (len(got) > 0 || len(s.Callbacks) > 0) {
+			return []string{"callbacks (- want, + got):\n" + diffLines(s.Callbacks, got)}
+		}
+	}
+	return nil
+}
+
+func matchError(err, expected error) bool {
+	if err == nil || expected == nil {
+		return err == expected
+	}
+	if errors.Is(err, expected) {
+		return true
+	}
+	// A zero value, unlike a sentinel, stands for any error of its type.
+	return reflect.ValueOf(expected).IsZero() && reflect.TypeOf(err) == reflect.TypeOf(expected)
+}
+
+func describeError(err error) string {
+	if err == nil {
+		return "no error"
+	}
+	if reflect.TypeOf(err) == sentinelType {
+		return err.Error()
+	}
+	return fmt.Sprintf("%T (%v)", err, err)
+}
+
+// sentinelType is the type of the errors created by errors.New.
+var sentinelType = reflect.TypeOf(errors.New(""))
+
+// diffLines returns the lines of want and got, prefixed with - for the
+// lines only in want, + for the lines only in got and spaces for the others.
+func diffLines(want, got []string) string {
+	// lcs[i][j] is the length of the longest common subsequence of want[i:]
+	// and got[j:].
+	lcs := make([][]int, len(want)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(got)+1)
+	}
+	for i := len(want) - 1; i >= 0; i-- {
+		for j := len(got) - 1; j >= 0; j-- {
+			switch {
+			case want[i] == got[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var b strings.Builder
+	i, j := 0, 0
+	for i < len(want) || j < len(got) {
+		switch {
+		case i < len(want) && j < len(got) && want[i] == got[j]:
+			fmt.Fprintf(&b, "\t  %s\n", want[i])
+			i, j = i+1, j+1
+		case j == len(got) || (i < len(want) && lcs[i+1][j] >= lcs[i][j+1]):
+			fmt.Fprintf(&b, "\t- %s\n", want[i])
+			i++
+		default:
+			fmt.Fprintf(&b, "\t+ %s\n", got[j])
+			j++
+		}
+	}
+	return b.String()
+}
+
+// callbackRecorder records the callbacks called for an instance.
+type callbackRecorder[STATE, EVENT comparable] struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+// Observe implements fsm.Observer.
+func (r *callbackRecorder[STATE, EVENT]) Observe(o fsm.Observation[STATE, EVENT]) {
+	if o.Phase == fsm.PhaseCallback {
+		r.mu.Lock()
+		r.calls = append(r.calls, o.Callback)
+		r.mu.Unlock()
+	}
+}
+
+func (r *callbackRecorder[STATE, EVENT]) callbacks() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls
+}
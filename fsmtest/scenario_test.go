@@ -0,0 +1,136 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsmtest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/maozhixiang/fsm"
+)
+
+type (
+	doorScenario = Scenario[string, string, door, any]
+	doorStep     = Step[string, string, any]
+)
+
+func newLockedDoorFSM() *fsm.FSM[string, string, door, any] {
+	locked := errors.New("locked")
+	return newDoorFSM().
+		Before("open", func(_ *door, e *fsm.Event[string, string, any]) {
+			if len(e.Args) > 0 && e.Args[0] == "locked" {
+				e.Cancel(locked)
+			}
+		}).
+		OnEnter("open", func(_ *door, _ *fsm.Event[string, string, any]) {}).
+		AfterAny(func(_ *door, _ *fsm.Event[string, string, any]) {})
+}
+
+func TestRunScenarios(t *testing.T) {
+	open := "open"
+	RunScenarios(t, newLockedDoorFSM(), []doorScenario{
+		{
+			Name: "multiple events",
+			Steps: []doorStep{
+				{Event: "open", State: "open"},
+				{Event: "close", State: "closed"},
+				{Event: "knock", State: "closed", Err: fsm.ErrNoTransition},
+			},
+			Callbacks: []string{"before_open", "enter_open", "after_event", "after_event", "after_event"},
+		},
+		{
+			Name: "cancel with error",
+			Steps: []doorStep{
+				{Event: "open", Args: []any{"locked"}, State: "closed", Err: fsm.CanceledError{}},
+			},
+			Callbacks: []string{"before_open"},
+		},
+		{
+			Name:  "start state",
+			Start: &open,
+			Steps: []doorStep{
+				{Event: "open", State: "open", Err: fsm.InvalidEventError[string, string]{}},
+			},
+			Callbacks: []string{},
+		},
+	})
+}
+
+func TestScenarioFailures(t *testing.T) {
+	model := newLockedDoorFSM()
+	failures := runScenario(model, doorScenario{
+		Steps: []doorStep{
+			{Event: "open", State: "open"},
+			{Event: "close", State: "open", Err: fsm.ErrCanceled},
+			{Event: "open", State: "open"},
+		},
+	})
+	expected := "step 2: event close from state open:\n" +
+		"\tstate: got closed, want open\n" +
+		"\terror: got no error, want transition canceled"
+	if len(failures) != 1 || failures[0] != expected {
+		t.Errorf("unexpected failures %q", failures)
+	}
+
+	failures = runScenario(model, doorScenario{
+		Steps:     []doorStep{{Event: "open", State: "open"}},
+		Callbacks: []string{"before_open", "leave_closed", "enter_open"},
+	})
+	expected = "callbacks (- want, + got):\n" +
+		"\t  before_open\n" +
+		"\t- leave_closed\n" +
+		"\t  enter_open\n" +
+		"\t+ after_event\n"
+	if len(failures) != 1 || failures[0] != expected {
+		t.Errorf("unexpected failures %q", failures)
+	}
+}
+
+func TestScenarioStartsFromZeroState(t *testing.T) {
+	type state int
+	const (
+		idle state = iota
+		running
+	)
+	model := fsm.NewFSM[state, string, door, any](running, []fsm.EventDesc[state, string]{
+		{Name: "start", Src: []state{idle}, Dst: running},
+	})
+	start := idle
+	RunScenarios(t, model, []Scenario[state, string, door, any]{{
+		Name:  "from idle",
+		Start: &start,
+		Steps: []Step[state, string, any]{{Event: "start", State: running}},
+	}})
+}
+
+func TestMatchError(t *testing.T) {
+	canceled := fsm.CanceledError{Err: errors.New("locked")}
+	tests := []struct {
+		err, expected error
+		match         bool
+	}{
+		{canceled, fsm.ErrCanceled, true},
+		{canceled, fsm.CanceledError{}, true},
+		{canceled, fsm.NoTransitionError{}, false},
+		{errors.New("other"), fsm.ErrCanceled, false},
+		{nil, nil, true},
+		{nil, fsm.ErrCanceled, false},
+	}
+	for _, test := range tests {
+		if matchError(test.err, test.expected) != test.match {
+			t.Errorf("expected match of %v with %v to be %t", test.err, test.expected, test.match)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsmtest
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/maozhixiang/fsm"
+)
+
+// Invariant checks a property that must hold for an instance after every
+// event, whatever its outcome.
+type Invariant[STATE, EVENT comparable, FSM_IMPL, ARG any] func(inst *fsm.Instance[STATE, EVENT, FSM_IMPL, ARG]) error
+
+// WalkOptions configures RandomWalk and FuzzWalk.
+type WalkOptions[STATE, EVENT comparable, FSM_IMPL, ARG any] struct {
+	// Walks is the number of instances driven, 100 by default.
+	Walks int
+	// Steps is the maximum number of events per walk, 50 by default. A walk
+	// stops earlier in a state without available event, unless InvalidRate
+	// is not zero.
+	Steps int
+	// Seed seeds the generator. The current time is used if it is zero, and
+	// reported on failure.
+	Seed int64
+	// InvalidRate is the probability, between 0 and 1, of firing an event
+	// that is not available in the current state. Such an event must fail
+	// and leave the instance unchanged.
+	InvalidRate float64
+
+	// NewInstance creates the instances, model.NewInstance by default.
+	NewInstance func() *fsm.Instance[STATE, EVENT, FSM_IMPL, ARG]
+	// Args returns the arguments of an event, none by default. It should be
+	// deterministic for failing sequences to be shrunk reliably.
+	Args func(event EVENT) []ARG
+	// Invariants are checked after every event.
+	Invariants []Invariant[STATE, EVENT, FSM_IMPL, ARG]
+}
+
+// RandomWalk drives fresh instances of model with random sequences of events
+// and checks the invariants after every event. A panic of a callback is a
+// failure too.
+//
+// On failure, the sequence is shrunk to a minimal one still failing, which is
+// reported with t.Fatalf along with the seed.
+func RandomWalk[STATE, EVENT comparable, FSM_IMPL, ARG any](t testing.TB, model *fsm.FSM[STATE, EVENT, FSM_IMPL, ARG], opts WalkOptions[STATE, EVENT, FSM_IMPL, ARG]) {
+	t.Helper()
+	w := newWalker(model, opts)
+	seed := opts.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	r := rand.New(rand.NewSource(seed))
+
+	for i := 0; i < w.opts.Walks; i++ {
+		inst := w.opts.NewInstance()
+		var sequence []EVENT
+		for len(sequence) < w.opts.Steps {
+			event, ok := w.pick(inst, r.Float64() < w.opts.InvalidRate, r.Intn)
+			if !ok {
+				break
+			}
+			sequence = append(sequence, event)
+			if err := w.step(inst, event); err != nil {
+				sequence, err = w.shrink(sequence, err)
+				t.Fatalf("random walk failed with seed %d: %v\nminimal sequence: %v", seed, err, sequence)
+				return
+			}
+		}
+	}
+}
+
+// FuzzWalk registers a fuzz target driving a fresh instance of model with
+// the events encoded by the fuzzed bytes, checking the invariants like
+// RandomWalk. Each byte picks an available event, or an unavailable one if
+// its high bit is set and InvalidRate is not zero. Walks and Seed are
+// ignored.
+//
+//	func FuzzOrder(f *testing.F) {
+//		fsmtest.FuzzWalk(f, newOrderFSM(), opts)
+//	}
+func FuzzWalk[STATE, EVENT comparable, FSM_IMPL, ARG any](f *testing.F, model *fsm.FSM[STATE, EVENT, FSM_IMPL, ARG], opts WalkOptions[STATE, EVENT, FSM_IMPL, ARG]) {
+	w := newWalker(model, opts)
+	f.Add([]byte{})
+	f.Add([]byte{0, 1, 2, 3})
+	f.Add([]byte{0x80, 0, 0x81, 1})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		inst := w.opts.NewInstance()
+		var sequence []EVENT
+		for _, b := range data {
+			if len(sequence) == w.opts.Steps {
+				break
+			}
+			invalid := b&0x80 != 0 && w.opts.InvalidRate > 0
+			event, ok := w.pick(inst, invalid, func(n int) int { return int(b&0x7f) % n })
+			if !ok {
+				break
+			}
+			sequence = append(sequence, event)
+			if err := w.step(inst, event); err != nil {
+				sequence, err = w.shrink(sequence, err)
+				t.Fatalf("walk failed: %v\nminimal sequence: %v", err, sequence)
+			}
+		}
+	})
+}
+
+type walker[STATE, EVENT comparable, FSM_IMPL, ARG any] struct {
+	opts WalkOptions[STATE, EVENT, FSM_IMPL, ARG]
+	// events are all the events of the model, in the order of model.Edges.
+	events []EVENT
+}
+
+func newWalker[STATE, EVENT comparable, FSM_IMPL, ARG any](model *fsm.FSM[STATE, EVENT, FSM_IMPL, ARG], opts WalkOptions[STATE, EVENT, FSM_IMPL, ARG]) *walker[STATE, EVENT, FSM_IMPL, ARG] {
+	if opts.Walks <= 0 {
+		opts.Walks = 100
+	}
+	if opts.Steps <= 0 {
+		opts.Steps = 50
+	}
+	if opts.NewInstance == nil {
+		opts.NewInstance = model.NewInstance
+	}
+	w := &walker[STATE, EVENT, FSM_IMPL, ARG]{opts: opts}
+	seen := make(map[EVENT]bool)
+	for _, e := range model.Edges() {
+		if !seen[e.Event] {
+			seen[e.Event] = true
+			w.events = append(w.events, e.Event)
+		}
+	}
+	return w
+}
+
+// pick chooses with intn the next event among the ones available in the
+// state of inst or, if invalid is true, among the others. It falls back to
+// the other kind of events if there is none of the one asked for, unavailable
+// events being only used if InvalidRate is not zero, and returns false if
+// there is none either.
+func (w *walker[STATE, EVENT, FSM_IMPL, ARG]) pick(inst *fsm.Instance[STATE, EVENT, FSM_IMPL, ARG], invalid bool, intn func(int) int) (EVENT, bool) {
+	var available, unavailable []EVENT
+	for _, e := range w.events {
+		if inst.Can(e) {
+			available = append(available, e)
+		} else {
+			unavailable = append(unavailable, e)
+		}
+	}
+	if w.opts.InvalidRate <= 0 {
+		unavailable = nil
+	}
+	candidates := available
+	if (invalid && len(unavailable) > 0) || len(available) == 0 {
+		candidates = unavailable
+	}
+	if len(candidates) == 0 {
+		var zero EVENT
+		return zero, false
+	}
+	return candidates[intn(len(candidates))], true
+}
+
+// step fires event and checks the invariants.
+func (w *walker[STATE, EVENT, FSM_IMPL, ARG]) step(inst *fsm.Instance[STATE, EVENT, FSM_IMPL, ARG], event EVENT) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("event %v panicked: %v", event, v)
+		}
+	}()
+
+	available := inst.Can(event)
+	state, version := inst.Current(), inst.Version()
+	var args []ARG
+	if w.opts.Args != nil {
+		args = w.opts.Args(event)
+	}
+	result := inst.Event(event, args...)
+	if !available && (result == nil || inst.Version() != version) {
+		return fmt.Errorf("event %v unavailable in state %v changed the instance", event, state)
+	}
+	for _, invariant := range w.opts.Invariants {
+		if err := invariant(inst); err != nil {
+			return fmt.Errorf("after event %v from state %v: %w", event, state, err)
+		}
+	}
+	return nil
+}
+
+// run replays sequence on a fresh instance. It returns the index of the
+// failing event and its error, or the length of sequence and nil.
+func (w *walker[STATE, EVENT, FSM_IMPL, ARG]) run(sequence []EVENT) (int, error) {
+	inst := w.opts.NewInstance()
+	for i, event := range sequence {
+		if err := w.step(inst, event); err != nil {
+			return i, err
+		}
+	}
+	return len(sequence), nil
+}
+
+// shrink removes events from the sequence failing with cause as long as it
+// keeps failing, and returns the shortest sequence found with its error.
+func (w *walker[STATE, EVENT, FSM_IMPL, ARG]) shrink(sequence []EVENT, cause error) ([]EVENT, error) {
+	n, err := w.run(sequence)
+	if err == nil {
+		// The failure cannot be reproduced, for example because callbacks
+		// are not deterministic.
+		return sequence, cause
+	}
+	sequence = sequence[:n+1]
+	for shrunk := true; shrunk; {
+		shrunk = false
+		for i := 0; i < len(sequence); i++ {
+			candidate := append(append([]EVENT(nil), sequence[:i]...), sequence[i+1:]...)
+			if n, cerr := w.run(candidate); cerr != nil {
+				sequence, err, shrunk = candidate[:n+1], cerr, true
+				i--
+			}
+		}
+	}
+	return sequence, err
+}
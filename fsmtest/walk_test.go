@@ -0,0 +1,128 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsmtest
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/maozhixiang/fsm"
+)
+
+// counter counts the open doors.
+type counter struct{ open, opened int }
+
+func newCounterFSM() *fsm.FSM[string, string, counter, any] {
+	return fsm.NewFSM[string, string, counter, any]("closed", []fsm.EventDesc[string, string]{
+		{Name: "open", Src: []string{"closed"}, Dst: "open"},
+		{Name: "close", Src: []string{"open"}, Dst: "closed"},
+		{Name: "knock", Src: []string{"closed"}, Dst: "closed"},
+	}).
+		SetFsmImplConstructor(func() *counter { return &counter{} }).
+		After("open", func(c *counter, _ *fsm.Event[string, string, any]) { c.open++ }).
+		After("close", func(c *counter, _ *fsm.Event[string, string, any]) { c.open-- })
+}
+
+func counterInvariant(inst *fsm.Instance[string, string, counter, any]) error {
+	if expected := map[string]int{"closed": 0, "open": 1}[inst.Current()]; inst.Self.open != expected {
+		return fmt.Errorf("expected %d open doors in state %s, got %d", expected, inst.Current(), inst.Self.open)
+	}
+	return nil
+}
+
+// fatalRecorder records the failure of a walk.
+type fatalRecorder struct {
+	testing.TB
+	message string
+}
+
+func (r *fatalRecorder) Helper() {}
+
+func (r *fatalRecorder) Fatalf(format string, args ...any) {
+	r.message = fmt.Sprintf(format, args...)
+}
+
+func TestRandomWalk(t *testing.T) {
+	opts := WalkOptions[string, string, counter, any]{
+		Seed:        1,
+		InvalidRate: 0.3,
+		Invariants:  []Invariant[string, string, counter, any]{counterInvariant},
+	}
+	RandomWalk(t, newCounterFSM(), opts)
+
+	// The knock bug is found and reduced to the open and knock events
+	// needed to reveal it.
+	buggy := newCounterFSM().
+		After("knock", func(c *counter, _ *fsm.Event[string, string, any]) { c.open++ })
+	r := &fatalRecorder{}
+	RandomWalk[string, string, counter, any](r, buggy, opts)
+	if !strings.Contains(r.message, "minimal sequence: [knock]") || !strings.Contains(r.message, "seed 1") {
+		t.Errorf("expected minimal sequence [knock], got %q", r.message)
+	}
+}
+
+func TestRandomWalkWithoutUnavailableEvent(t *testing.T) {
+	// Every event is available in every state, so unavailable events cannot
+	// be picked and available ones are fired instead.
+	f := fsm.NewFSM[string, string, counter, any]("off", []fsm.EventDesc[string, string]{
+		{Name: "flip", Src: []string{"off"}, Dst: "on"},
+		{Name: "flip", Src: []string{"on"}, Dst: "off"},
+	})
+	steps := 0
+	RandomWalk(t, f, WalkOptions[string, string, counter, any]{
+		Walks:       2,
+		Steps:       20,
+		Seed:        1,
+		InvalidRate: 1,
+		Invariants: []Invariant[string, string, counter, any]{
+			func(*fsm.Instance[string, string, counter, any]) error { steps++; return nil },
+		},
+	})
+	if steps != 40 {
+		t.Errorf("expected 2 walks of 20 steps, got %d steps", steps)
+	}
+}
+
+func TestShrink(t *testing.T) {
+	w := newWalker(newCounterFSM().OnEnter("open", func(c *counter, e *fsm.Event[string, string, any]) {
+		if c.opened++; c.opened == 2 {
+			panic("second opening")
+		}
+	}), WalkOptions[string, string, counter, any]{})
+
+	sequence := []string{"knock", "open", "close", "knock", "open", "knock", "close"}
+	n, err := w.run(sequence)
+	if n != 4 || err == nil {
+		t.Fatalf("expected second open to fail, got %d, %v", n, err)
+	}
+	shrunk, err := w.shrink(sequence, err)
+	if !reflect.DeepEqual(shrunk, []string{"open", "close", "open"}) || !strings.Contains(err.Error(), "second opening") {
+		t.Errorf("unexpected shrunk sequence %v: %v", shrunk, err)
+	}
+
+	w = newWalker(newCounterFSM(), WalkOptions[string, string, counter, any]{})
+	if n, err := w.run([]string{"close", "open"}); n != 2 || err != nil {
+		t.Errorf("expected refused unavailable event not to fail, got %v", err)
+	}
+}
+
+func FuzzCounterWalk(f *testing.F) {
+	FuzzWalk(f, newCounterFSM(), WalkOptions[string, string, counter, any]{
+		InvalidRate: 1,
+		Invariants:  []Invariant[string, string, counter, any]{counterInvariant},
+	})
+}
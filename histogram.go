@@ -0,0 +1,126 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsm
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// LatencyBounds are the default upper bounds of the buckets of a latency
+// Histogram.
+var LatencyBounds = []time.Duration{
+	10 * time.Microsecond,
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+	10 * time.Second,
+}
+
+// Histogram counts durations in buckets. It is safe for concurrent use and
+// implements expvar.Var.
+type Histogram struct {
+	mu     sync.Mutex
+	bounds []time.Duration
+	// counts has one more element than bounds for the overflow bucket.
+	counts []uint64
+	count  uint64
+	sum    time.Duration
+}
+
+// Bucket is the number of durations counted up to an upper bound.
+type Bucket struct {
+	// UpperBound is the inclusive upper bound of the bucket, or zero for the
+	// last bucket, which has no bound.
+	UpperBound time.Duration
+	Count      uint64
+}
+
+// NewHistogram creates a histogram with buckets of the given ascending upper
+// bounds, or LatencyBounds if none are given.
+func NewHistogram(bounds ...time.Duration) *Histogram {
+	if len(bounds) == 0 {
+		bounds = LatencyBounds
+	}
+	return &Histogram{
+		bounds: append([]time.Duration(nil), bounds...),
+		counts: make([]uint64, len(bounds)+1),
+	}
+}
+
+// Observe counts the duration d.
+func (h *Histogram) Observe(d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	i := 0
+	for i < len(h.bounds) && d > h.bounds[i] {
+		i++
+	}
+	h.counts[i]++
+	h.count++
+	h.sum += d
+}
+
+// Count returns the number of durations counted.
+func (h *Histogram) Count() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.count
+}
+
+// Sum returns the sum of the durations counted.
+func (h *Histogram) Sum() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.sum
+}
+
+// Buckets returns the buckets of the histogram, the overflow bucket last.
+func (h *Histogram) Buckets() []Bucket {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	buckets := make([]Bucket, len(h.counts))
+	for i, count := range h.counts {
+		buckets[i].Count = count
+		if i < len(h.bounds) {
+			buckets[i].UpperBound = h.bounds[i]
+		}
+	}
+	return buckets
+}
+
+// String returns the histogram as a JSON object.
+func (h *Histogram) String() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf(`{"count": %d, "sum": %q, "buckets": {`, h.count, h.sum))
+	for i, count := range h.counts {
+		bound := "+Inf"
+		if i < len(h.bounds) {
+			bound = h.bounds[i].String()
+		}
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(fmt.Sprintf(`%q: %d`, bound, count))
+	}
+	buf.WriteString("}}")
+	return buf.String()
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsm
+
+import (
+	"sync"
+	"time"
+)
+
+// Transition is the record of a single call to Instance.Event.
+type Transition[STATE, EVENT comparable] struct {
+	// InstanceID is the ID of the instance that performed the transition.
+	InstanceID string
+
+	// Event is the event name.
+	Event EVENT
+
+	// Src is the state the instance was in when the event was dispatched.
+	Src STATE
+
+	// Dst is the destination state of the transition. It equals Src when
+	// the event was rejected because it is unknown or invalid in Src.
+	Dst STATE
+
+	// Time is the time the event was dispatched.
+	Time time.Time
+
+	// Err is the error returned by Instance.Event, if any.
+	Err error
+
+	// Committed is true if the instance moved to Dst. A committed transition
+	// may still carry an Err set by an enter or after callback.
+	Committed bool
+
+	// Undo is true if the transition was performed by Instance.Undo.
+	Undo bool
+}
+
+// history is a bounded ring buffer of transitions.
+type history[STATE, EVENT comparable] struct {
+	mu      sync.Mutex
+	entries []Transition[STATE, EVENT]
+	// next is the index the next entry is written to.
+	next int
+	// full is set once the buffer wrapped around.
+	full bool
+}
+
+// add appends t, overwriting the oldest entry if the buffer is full. It is a
+// no-op while the history is disabled.
+func (h *history[STATE, EVENT]) add(t Transition[STATE, EVENT]) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.entries) == 0 {
+		return
+	}
+	h.entries[h.next] = t
+	h.next++
+	if h.next == len(h.entries) {
+		h.next = 0
+		h.full = true
+	}
+}
+
+// list returns the recorded transitions, oldest first.
+func (h *history[STATE, EVENT]) list() []Transition[STATE, EVENT] {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.ordered()
+}
+
+func (h *history[STATE, EVENT]) ordered() []Transition[STATE, EVENT] {
+	if !h.full {
+		return append([]Transition[STATE, EVENT](nil), h.entries[:h.next]...)
+	}
+	list := make([]Transition[STATE, EVENT], 0, len(h.entries))
+	list = append(list, h.entries[h.next:]...)
+	return append(list, h.entries[:h.next]...)
+}
+
+// lastUndoable returns the most recent committed transition that has not been
+// undone yet.
+func (h *history[STATE, EVENT]) lastUndoable() (Transition[STATE, EVENT], bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	list := h.ordered()
+	undone := 0
+	for i := len(list) - 1; i >= 0; i-- {
+		t := list[i]
+		switch {
+		case !t.Committed:
+		case t.Undo:
+			undone++
+		case undone > 0:
+			undone--
+		default:
+			return t, true
+		}
+	}
+	return Transition[STATE, EVENT]{}, false
+}
+
+// setSize changes the capacity of the history, keeping the most recent
+// entries. A size of zero or less disables the history.
+func (h *history[STATE, EVENT]) setSize(size int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if size < 0 {
+		size = 0
+	}
+	list := h.ordered()
+	if len(list) > size {
+		list = list[len(list)-size:]
+	}
+	h.entries = make([]Transition[STATE, EVENT], size)
+	h.next = copy(h.entries, list)
+	h.full = false
+	if size > 0 && h.next == size {
+		h.next = 0
+		h.full = true
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsm
+
+import (
+	"errors"
+	"testing"
+)
+
+type door struct{}
+
+func newDoorFSM() *FSM[string, string, door, any] {
+	return NewFSM[string, string, door, any]("closed", []EventDesc[string, string]{
+		{Name: "open", Src: []string{"closed"}, Dst: "open"},
+		{Name: "close", Src: []string{"open"}, Dst: "closed"},
+		{Name: "knock", Src: []string{"closed"}, Dst: "closed"},
+	})
+}
+
+func TestHistoryDisabledByDefault(t *testing.T) {
+	inst := newDoorFSM().NewInstance()
+	inst.Event("open")
+	if len(inst.History()) != 0 {
+		t.Error("expected history to be disabled by default")
+	}
+}
+
+func TestHistoryRecordsOutcomes(t *testing.T) {
+	canceled := errors.New("locked")
+	inst := newDoorFSM().
+		Before("close", func(_ *door, e *Event[string, string, any]) { e.Cancel(canceled) }).
+		SetHistorySize(10).
+		NewInstance()
+
+	inst.Event("open")
+	inst.Event("close")
+	inst.Event("open")
+	inst.Event("lock")
+
+	h := inst.History()
+	if len(h) != 4 {
+		t.Fatalf("expected 4 recorded transitions, got %d", len(h))
+	}
+	if h[0].Src != "closed" || h[0].Dst != "open" || !h[0].Committed || h[0].Err != nil {
+		t.Errorf("unexpected first transition %+v", h[0])
+	}
+	if _, ok := h[1].Err.(CanceledError); !ok || h[1].Committed || h[1].Dst != "closed" {
+		t.Errorf("expected canceled transition, got %+v", h[1])
+	}
+	if _, ok := h[2].Err.(InvalidEventError[string, string]); !ok || h[2].Dst != "open" {
+		t.Errorf("expected invalid event, got %+v", h[2])
+	}
+	if _, ok := h[3].Err.(UnknownEventError[string]); !ok {
+		t.Errorf("expected unknown event, got %+v", h[3])
+	}
+	if h[0].Time.IsZero() {
+		t.Error("expected transition time to be recorded")
+	}
+}
+
+func TestHistoryIsBounded(t *testing.T) {
+	inst := newDoorFSM().NewInstance().SetHistorySize(3)
+	for _, e := range []string{"open", "close", "knock", "open", "close"} {
+		inst.Event(e)
+	}
+	h := inst.History()
+	if len(h) != 3 {
+		t.Fatalf("expected 3 recorded transitions, got %d", len(h))
+	}
+	if h[0].Event != "knock" || h[1].Event != "open" || h[2].Event != "close" {
+		t.Errorf("expected most recent transitions oldest first, got %+v", h)
+	}
+	if _, ok := h[0].Err.(NoTransitionError); !ok {
+		t.Errorf("expected no transition error, got %v", h[0].Err)
+	}
+
+	inst.SetHistorySize(2)
+	h = inst.History()
+	if len(h) != 2 || h[0].Event != "open" || h[1].Event != "close" {
+		t.Errorf("expected resize to keep most recent transitions, got %+v", h)
+	}
+}
+
+func TestUndo(t *testing.T) {
+	var calls []string
+	record := func(name string) Callback[string, string, door, any] {
+		return func(_ *door, e *Event[string, string, any]) {
+			if e.Undo {
+				calls = append(calls, name)
+			}
+		}
+	}
+	f := NewFSM[string, string, door, any]("draft", []EventDesc[string, string]{
+		{Name: "review", Src: []string{"draft"}, Dst: "reviewed"},
+		{Name: "publish", Src: []string{"reviewed"}, Dst: "published"},
+		{Name: "archive", Src: []string{"published"}, Dst: "archived"},
+	}).
+		SetHistorySize(10).
+		Irreversible("archive").
+		OnLeave("published", record("leave_published")).
+		OnEnter("reviewed", record("enter_reviewed")).
+		OnUndo("review", record("undo_review"))
+
+	inst := f.NewInstance()
+	inst.Event("review")
+	inst.Event("publish")
+	inst.Event("archive")
+	if err := inst.Undo(); !errors.Is(err, ErrIrreversible) {
+		t.Error("expected irreversible event to be refused")
+	}
+	inst.SetState("published")
+	if err := inst.Undo(); !errors.Is(err, ErrNoUndo) {
+		t.Errorf("expected NoUndoError after SetState, got %v", err)
+	}
+
+	inst = f.NewInstance()
+	inst.Event("review")
+	inst.Event("publish")
+	if err := inst.Undo(); err != nil || inst.Current() != "reviewed" {
+		t.Fatalf("expected undo to reviewed, got %v in %s", err, inst.Current())
+	}
+	if err := inst.Undo(); err != nil || inst.Current() != "draft" {
+		t.Fatalf("expected undo to draft, got %v in %s", err, inst.Current())
+	}
+	if err := inst.Undo(); !errors.Is(err, ErrNoUndo) {
+		t.Errorf("expected NoUndoError, got %v", err)
+	}
+	if len(calls) != 3 || calls[0] != "leave_published" || calls[1] != "enter_reviewed" || calls[2] != "undo_review" {
+		t.Errorf("unexpected callbacks %v", calls)
+	}
+	if h := inst.History(); len(h) != 4 || !h[2].Undo || h[2].Src != "published" || h[2].Dst != "reviewed" {
+		t.Errorf("expected undo to be recorded, got %+v", h)
+	}
+}
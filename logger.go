@@ -0,0 +1,106 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Logger is the logging interface used to record transitions. Its methods
+// take a message followed by alternating keys and values, so that a
+// *slog.Logger can be used directly.
+type Logger interface {
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+}
+
+// maxLoggedArgLen is the length beyond which a logged argument is truncated.
+const maxLoggedArgLen = 64
+
+// SetLogger sets the logger recording the transitions of all instances of the
+// model that have no logger of their own.
+func (f *FSM[STATE, EVENT, FSM_IMPL, ARG]) SetLogger(logger Logger) *FSM[STATE, EVENT, FSM_IMPL, ARG] {
+	f.logger = logger
+	return f
+}
+
+// SetLogger sets the logger recording the transitions of the instance,
+// overriding the logger of the model. It must be called before the instance
+// is shared.
+func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) SetLogger(logger Logger) *Instance[STATE, EVENT, FSM_IMPL, ARG] {
+	f.logger = logger
+	return f
+}
+
+// log records t with the logger of the instance, or else of the model.
+// Committed and canceled transitions are logged at info level, rejected
+// events, panicking callbacks and other failures at warn level.
+func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) log(t Transition[STATE, EVENT], args []ARG) {
+	logger := f.logger
+	if logger == nil {
+		logger = f.FSM.logger
+	}
+	if logger == nil {
+		return
+	}
+
+	kv := []any{
+		"instance", f.id,
+		"event", t.Event,
+		"src", t.Src,
+		"dst", t.Dst,
+	}
+	if len(args) > 0 {
+		kv = append(kv, "args", summarizeArgs(args))
+	}
+	if t.Err != nil {
+		kv = append(kv, "error", t.Err)
+	}
+
+	switch err := t.Err.(type) {
+	case CanceledError:
+		logger.Info("fsm transition canceled", kv...)
+	case NoTransitionError:
+		logger.Info("fsm event without transition", kv...)
+	case InvalidEventError[STATE, EVENT], UnknownEventError[EVENT], ConflictError[STATE]:
+		logger.Warn("fsm event rejected", kv...)
+	case CallbackPanicError:
+		kv = append(kv, "callback", err.Hook, "reverted", err.Reverted)
+		logger.Warn("fsm callback panicked", kv...)
+	default:
+		switch {
+		case !t.Committed:
+			logger.Warn("fsm transition failed", kv...)
+		case t.Undo:
+			logger.Info("fsm transition undone", kv...)
+		default:
+			logger.Info("fsm transition", kv...)
+		}
+	}
+}
+
+// summarizeArgs formats args for logging, truncating long values.
+func summarizeArgs[ARG any](args []ARG) string {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		s := fmt.Sprint(arg)
+		if len(s) > maxLoggedArgLen {
+			s = s[:maxLoggedArgLen] + "..."
+		}
+		parts[i] = s
+	}
+	return "[" + strings.Join(parts, " ") + "]"
+}
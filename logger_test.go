@@ -0,0 +1,77 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsm
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type testLogger struct {
+	lines []string
+}
+
+func (l *testLogger) Info(msg string, args ...any) { l.add("INFO", msg, args) }
+func (l *testLogger) Warn(msg string, args ...any) { l.add("WARN", msg, args) }
+
+func (l *testLogger) add(level, msg string, args []any) {
+	line := level + " " + msg
+	for i := 0; i+1 < len(args); i += 2 {
+		line += fmt.Sprintf(" %v=%v", args[i], args[i+1])
+	}
+	l.lines = append(l.lines, line)
+}
+
+func TestLogger(t *testing.T) {
+	modelLogger, instLogger := &testLogger{}, &testLogger{}
+	f := newDoorFSM().
+		Before("close", func(_ *door, e *Event[string, string, any]) { e.Cancel() }).
+		SetLogger(modelLogger)
+
+	inst := f.NewInstance().SetID("front")
+	inst.Event("open", "key", strings.Repeat("x", 100))
+	inst.Event("close")
+	inst.Event("lock")
+	f.NewInstance().SetID("back").SetLogger(instLogger).Event("knock")
+
+	expected := []string{
+		"INFO fsm transition instance=front event=open src=closed dst=open args=[key " + strings.Repeat("x", 64) + "...]",
+		"INFO fsm transition canceled instance=front event=close src=open dst=closed error=transition canceled",
+		"WARN fsm event rejected instance=front event=lock src=open dst=open error=event lock does not exist",
+	}
+	if strings.Join(modelLogger.lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected model logger lines\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(modelLogger.lines, "\n"))
+	}
+	if len(instLogger.lines) != 1 || !strings.HasPrefix(instLogger.lines[0], "INFO fsm event without transition instance=back") {
+		t.Errorf("expected instance logger to override the model logger, got %v", instLogger.lines)
+	}
+}
+
+func TestLoggerPanic(t *testing.T) {
+	logger := &testLogger{}
+	inst := newDoorFSM().
+		OnEnter("open", func(_ *door, _ *Event[string, string, any]) { panic("boom") }).
+		SetPanicPolicy(PanicRevert).
+		SetLogger(logger).
+		NewInstance().
+		SetID("front")
+	inst.Event("open")
+
+	expected := "WARN fsm callback panicked instance=front event=open src=closed dst=open error=callback enter_open panicked: boom callback=enter_open reverted=true"
+	if len(logger.lines) != 1 || logger.lines[0] != expected {
+		t.Errorf("expected line\n%s\ngot\n%s", expected, strings.Join(logger.lines, "\n"))
+	}
+}
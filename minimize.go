@@ -0,0 +1,225 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Minimize returns an equivalent model with as few states as possible, along
+// with the state of the new model standing for each state of f.
+//
+// Two states are merged when they accept the same events, leading to merged
+// states, and have the same timeout. States with enter or leave callbacks are
+// never merged, since callbacks cannot be told apart. States are not merged
+// either if that would turn a transition between them into a self
+// transition, which does not call the state callbacks. Unreachable states are
+// kept, since SetState may still use them.
+//
+// Merged states are represented by the initial state, if it is one of them,
+// or by the first of them in sorted order. The new model has the callbacks
+// and settings of f, but not its subscriptions and dwell statistics.
+func (f *FSM[STATE, EVENT, FSM_IMPL, ARG]) Minimize() (*FSM[STATE, EVENT, FSM_IMPL, ARG], map[STATE]STATE) {
+	states := f.states()
+	events := f.events()
+	index := make(map[STATE]int, len(states))
+	for i, s := range states {
+		index[s] = i
+	}
+
+	// Start from the states that accept the same events and have the same
+	// callbacks, then split the classes whose states lead to different
+	// classes until nothing changes.
+	class := make(map[STATE]int, len(states))
+	count := partition(states, class, func(s STATE) string {
+		var key strings.Builder
+		for _, e := range events {
+			_, ok := f.transitions[eKey[STATE, EVENT]{e, s}]
+			fmt.Fprintf(&key, "%t,", ok)
+		}
+		if cb := f.stateCallbackFunc[s]; cb.enter != nil || cb.leave != nil {
+			// Functions cannot be compared, and closures of the same
+			// function literal share their code, so a state with callbacks
+			// is kept alone.
+			return fmt.Sprintf("state %d", index[s])
+		}
+		fmt.Fprintf(&key, "%v", f.timeouts[s])
+		return key.String()
+	})
+	for {
+		next := make(map[STATE]int, len(states))
+		n := partition(states, next, func(s STATE) string {
+			var key strings.Builder
+			fmt.Fprintf(&key, "%d|", class[s])
+			for _, e := range events {
+				dst, ok := f.transitions[eKey[STATE, EVENT]{e, s}]
+				if !ok {
+					continue
+				}
+				if dst != s && class[dst] == class[s] {
+					// Keep s alone rather than making e a self transition.
+					return fmt.Sprintf("%d|state %d", class[s], index[s])
+				}
+				fmt.Fprintf(&key, "%d,%t,", class[dst], dst == s)
+			}
+			return key.String()
+		})
+		class = next
+		if n == count {
+			break
+		}
+		count = n
+	}
+
+	representatives := make(map[int]STATE, count)
+	representatives[class[f.initial]] = f.initial
+	for _, s := range states {
+		if _, ok := representatives[class[s]]; !ok {
+			representatives[class[s]] = s
+		}
+	}
+	mapping := make(map[STATE]STATE, len(states))
+	for _, s := range states {
+		mapping[s] = representatives[class[s]]
+	}
+
+	m := NewFSM[STATE, EVENT, FSM_IMPL, ARG](f.initial, nil)
+	for key, dst := range f.transitions {
+		m.transitions[eKey[STATE, EVENT]{key.event, mapping[key.src]}] = mapping[dst]
+	}
+	for _, s := range representatives {
+		if cb, ok := f.stateCallbackFunc[s]; ok {
+			m.stateCallbackFunc[s] = cb
+		}
+		if t, ok := f.timeouts[s]; ok {
+			m.timeouts[s] = t
+		}
+	}
+	for e, cb := range f.eventCallbackFunc {
+		m.eventCallbackFunc[e] = cb
+	}
+	for e, cb := range f.undoFunc {
+		m.undoFunc[e] = cb
+	}
+	for e := range f.irreversible {
+		m.irreversible[e] = true
+	}
+	for name := range f.dryRunSafe {
+		m.dryRunSafe[name] = true
+	}
+	m.fsmImplConstructor = f.fsmImplConstructor
+	m.allStateCallbackFunc = f.allStateCallbackFunc
+	m.allEventCallbackFunc = f.allEventCallbackFunc
+	m.historySize = f.historySize
+	m.subscriptionPolicy = f.subscriptionPolicy
+	m.panicPolicy = f.panicPolicy
+	m.observers = append(m.observers, f.observers...)
+	m.logger = f.logger
+	m.clock = f.clock
+	return m, mapping
+}
+
+// Equivalent returns true if the models a and b accept the same sequences of
+// events from their initial states. Otherwise it returns a shortest sequence
+// of events accepted by only one of them, the last event being the one
+// refused by the other. Callbacks are not compared.
+func Equivalent[STATE, EVENT comparable, FSM_IMPL, ARG any](a, b *FSM[STATE, EVENT, FSM_IMPL, ARG]) (bool, []EVENT) {
+	type pair struct{ a, b STATE }
+	type step struct {
+		from  pair
+		event EVENT
+	}
+	events := a.events()
+	for _, e := range b.events() {
+		if !containsEvent(events, e) {
+			events = append(events, e)
+		}
+	}
+	sortEvents(events)
+
+	path := func(parents map[pair]step, p pair, start pair) []EVENT {
+		var events []EVENT
+		for p != start {
+			events = append([]EVENT{parents[p].event}, events...)
+			p = parents[p].from
+		}
+		return events
+	}
+
+	start := pair{a.initial, b.initial}
+	parents := map[pair]step{start: {}}
+	queue := []pair{start}
+	for i := 0; i < len(queue); i++ {
+		p := queue[i]
+		for _, e := range events {
+			da, okA := a.transitions[eKey[STATE, EVENT]{e, p.a}]
+			db, okB := b.transitions[eKey[STATE, EVENT]{e, p.b}]
+			if okA != okB {
+				return false, append(path(parents, p, start), e)
+			}
+			next := pair{da, db}
+			if _, seen := parents[next]; okA && !seen {
+				parents[next] = step{p, e}
+				queue = append(queue, next)
+			}
+		}
+	}
+	return true, nil
+}
+
+// events returns the events of the transitions, sorted.
+func (f *FSM[STATE, EVENT, FSM_IMPL, ARG]) events() []EVENT {
+	var events []EVENT
+	for key := range f.transitions {
+		if !containsEvent(events, key.event) {
+			events = append(events, key.event)
+		}
+	}
+	sortEvents(events)
+	return events
+}
+
+func containsEvent[EVENT comparable](events []EVENT, e EVENT) bool {
+	for _, event := range events {
+		if event == e {
+			return true
+		}
+	}
+	return false
+}
+
+func sortEvents[EVENT comparable](events []EVENT) {
+	sort.Slice(events, func(i, j int) bool {
+		return fmt.Sprint(events[i]) < fmt.Sprint(events[j])
+	})
+}
+
+// partition numbers the classes of states having the same key, in the order
+// of states, and returns the number of classes.
+func partition[STATE comparable](states []STATE, class map[STATE]int, key func(STATE) string) int {
+	ids := make(map[string]int)
+	for _, s := range states {
+		k := key(s)
+		id, ok := ids[k]
+		if !ok {
+			id = len(ids)
+			ids[k] = id
+		}
+		class[s] = id
+	}
+	return len(ids)
+}
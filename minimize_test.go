@@ -0,0 +1,106 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsm
+
+import (
+	"reflect"
+	"testing"
+)
+
+// newReviewFSM has two copies of the review step, one per channel.
+func newReviewFSM() *FSM[string, string, door, any] {
+	return NewFSM[string, string, door, any]("draft", []EventDesc[string, string]{
+		{Name: "submit_web", Src: []string{"draft"}, Dst: "review_web"},
+		{Name: "submit_mail", Src: []string{"draft"}, Dst: "review_mail"},
+		{Name: "approve", Src: []string{"review_web", "review_mail"}, Dst: "approved"},
+		{Name: "reject", Src: []string{"review_web"}, Dst: "draft"},
+		{Name: "reject", Src: []string{"review_mail"}, Dst: "draft"},
+	})
+}
+
+func TestMinimize(t *testing.T) {
+	f := newReviewFSM()
+	m, mapping := f.Minimize()
+
+	if mapping["review_web"] != "review_mail" || mapping["review_mail"] != "review_mail" || mapping["draft"] != "draft" {
+		t.Errorf("expected review states to be merged, got %v", mapping)
+	}
+	if got := m.states(); !reflect.DeepEqual(got, []string{"approved", "draft", "review_mail"}) {
+		t.Errorf("unexpected states %v", got)
+	}
+	if equivalent, counterexample := Equivalent(f, m); !equivalent {
+		t.Errorf("expected minimized model to be equivalent, got counterexample %v", counterexample)
+	}
+
+	// A callback on one of the states tells them apart.
+	f.OnEnter("review_web", func(_ *door, _ *Event[string, string, any]) {})
+	if _, mapping := f.Minimize(); mapping["review_web"] != "review_web" {
+		t.Errorf("expected states with different callbacks to be kept, got %v", mapping)
+	}
+}
+
+func TestMinimizeKeepsClosures(t *testing.T) {
+	var calls []string
+	record := func(name string) Callback[string, string, door, any] {
+		return func(_ *door, _ *Event[string, string, any]) { calls = append(calls, name) }
+	}
+	f := NewFSM[string, string, door, any]("a", []EventDesc[string, string]{
+		{Name: "go1", Src: []string{"a"}, Dst: "b"},
+		{Name: "go2", Src: []string{"a"}, Dst: "c"},
+	}).
+		OnEnter("b", record("billing")).
+		OnEnter("c", record("cancel"))
+
+	m, mapping := f.Minimize()
+	if mapping["c"] != "c" {
+		t.Errorf("expected states with different closures to be kept, got %v", mapping)
+	}
+	m.NewInstance().Event("go2")
+	if len(calls) != 1 || calls[0] != "cancel" {
+		t.Errorf("expected the cancel callback to be called, got %v", calls)
+	}
+
+	f.Before("go1", func(_ *door, e *Event[string, string, any]) { e.Cancel() }).DryRunSafe("before_go1")
+	m, _ = f.Minimize()
+	if sim := m.NewInstance().Simulate("go1"); sim.CanceledBy != "before_go1" || len(sim.Unevaluated) != 0 {
+		t.Errorf("expected dry run safe callbacks to be kept, got %+v", sim)
+	}
+}
+
+func TestMinimizeKeepsTransitions(t *testing.T) {
+	// Merging on and off would turn toggle into a self transition.
+	f := NewFSM[string, string, door, any]("off", []EventDesc[string, string]{
+		{Name: "toggle", Src: []string{"off"}, Dst: "on"},
+		{Name: "toggle", Src: []string{"on"}, Dst: "off"},
+	})
+	if m, _ := f.Minimize(); len(m.states()) != 2 {
+		t.Errorf("expected both states to be kept, got %v", m.states())
+	}
+}
+
+func TestEquivalent(t *testing.T) {
+	a := newReviewFSM()
+	b := newReviewFSM().AddTransition("approve", []string{"draft"}, "approved")
+	equivalent, counterexample := Equivalent(a, b)
+	if equivalent || !reflect.DeepEqual(counterexample, []string{"approve"}) {
+		t.Errorf("expected counterexample [approve], got %v, %v", equivalent, counterexample)
+	}
+
+	c := newReviewFSM().AddTransition("archive", []string{"approved"}, "archived")
+	equivalent, counterexample = Equivalent(a, c)
+	if equivalent || !reflect.DeepEqual(counterexample, []string{"submit_mail", "approve", "archive"}) {
+		t.Errorf("expected counterexample [submit_mail approve archive], got %v, %v", equivalent, counterexample)
+	}
+}
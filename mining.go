@@ -0,0 +1,201 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsm
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+)
+
+// MinedTransition is a transition observed in traces, with the number of
+// times it was observed.
+type MinedTransition[STATE, EVENT comparable] struct {
+	Src   STATE
+	Event EVENT
+	Dst   STATE
+	Count int
+
+	// Dominant is false if the same event from the same state was observed
+	// more often leading to another state. Such a transition is left out of
+	// the model, which cannot lead to two states.
+	Dominant bool
+}
+
+// MinedModel is a model inferred from traces by Mine.
+type MinedModel[STATE, EVENT comparable] struct {
+	// Initial is the most frequent source state of the first step of the
+	// traces.
+	Initial STATE
+	// Traces is the number of traces the model was inferred from.
+	Traces int
+	// Transitions are the transitions observed, sorted by source state,
+	// event and destination state.
+	Transitions []MinedTransition[STATE, EVENT]
+}
+
+// Mine infers a model from traces, each trace recording the steps of a
+// single instance in order. Every step observed becomes a transition.
+//
+// The result is a candidate to be reviewed: it only knows the transitions
+// that happened, and the same event observed leading from a state to
+// different states, which a model cannot express, is resolved in favor of
+// the most frequent destination.
+func Mine[STATE, EVENT comparable](traces [][]Step[STATE, EVENT]) MinedModel[STATE, EVENT] {
+	m := MinedModel[STATE, EVENT]{Traces: len(traces)}
+
+	counts := make(map[Step[STATE, EVENT]]int)
+	initials := make(map[STATE]int)
+	var initialOrder []STATE
+	for _, trace := range traces {
+		if len(trace) > 0 {
+			if initials[trace[0].Src] == 0 {
+				initialOrder = append(initialOrder, trace[0].Src)
+			}
+			initials[trace[0].Src]++
+		}
+		for _, step := range trace {
+			counts[step]++
+		}
+	}
+
+	sort.SliceStable(initialOrder, func(i, j int) bool {
+		return initials[initialOrder[i]] > initials[initialOrder[j]]
+	})
+	if len(initialOrder) > 0 {
+		m.Initial = initialOrder[0]
+	}
+
+	dominant := make(map[eKey[STATE, EVENT]]Step[STATE, EVENT])
+	for step, count := range counts {
+		m.Transitions = append(m.Transitions, MinedTransition[STATE, EVENT]{Src: step.Src, Event: step.Event, Dst: step.Dst, Count: count})
+	}
+	sort.Slice(m.Transitions, func(i, j int) bool {
+		a, b := m.Transitions[i], m.Transitions[j]
+		if a.Src != b.Src {
+			return fmt.Sprint(a.Src) < fmt.Sprint(b.Src)
+		}
+		if a.Event != b.Event {
+			return fmt.Sprint(a.Event) < fmt.Sprint(b.Event)
+		}
+		return fmt.Sprint(a.Dst) < fmt.Sprint(b.Dst)
+	})
+	for _, t := range m.Transitions {
+		key := eKey[STATE, EVENT]{t.Event, t.Src}
+		if best, ok := dominant[key]; !ok || t.Count > counts[best] {
+			dominant[key] = Step[STATE, EVENT]{Event: t.Event, Src: t.Src, Dst: t.Dst}
+		}
+	}
+	for i, t := range m.Transitions {
+		m.Transitions[i].Dominant = dominant[eKey[STATE, EVENT]{t.Event, t.Src}].Dst == t.Dst
+	}
+	return m
+}
+
+// EventDescs returns the dominant transitions as events, sorted by name then
+// destination state.
+func (m MinedModel[STATE, EVENT]) EventDescs() []EventDesc[STATE, EVENT] {
+	var events []EventDesc[STATE, EVENT]
+	index := make(map[Edge[STATE, EVENT]]int)
+	for _, t := range m.dominant() {
+		// Src is left zero to group the sources of an event and destination.
+		key := Edge[STATE, EVENT]{Event: t.Event, Dst: t.Dst}
+		i, ok := index[key]
+		if !ok {
+			i = len(events)
+			index[key] = i
+			events = append(events, EventDesc[STATE, EVENT]{Name: t.Event, Dst: t.Dst})
+		}
+		events[i].Src = append(events[i].Src, t.Src)
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].Name != events[j].Name {
+			return fmt.Sprint(events[i].Name) < fmt.Sprint(events[j].Name)
+		}
+		return fmt.Sprint(events[i].Dst) < fmt.Sprint(events[j].Dst)
+	})
+	return events
+}
+
+// NewMinedFSM constructs an FSM model from the dominant transitions of m.
+func NewMinedFSM[STATE, EVENT comparable, FSM_IMPL, ARG any](m MinedModel[STATE, EVENT]) *FSM[STATE, EVENT, FSM_IMPL, ARG] {
+	return NewFSM[STATE, EVENT, FSM_IMPL, ARG](m.Initial, m.EventDescs())
+}
+
+func (m MinedModel[STATE, EVENT]) dominant() []MinedTransition[STATE, EVENT] {
+	var transitions []MinedTransition[STATE, EVENT]
+	for _, t := range m.Transitions {
+		if t.Dominant {
+			transitions = append(transitions, t)
+		}
+	}
+	return transitions
+}
+
+// GoCode outputs the Go source of a function named name constructing the
+// model with NewFSM, the number of observations of each transition as
+// comments. States and events are written with the %#v verb, which suits
+// strings, numbers and the types based on them, and their types with the %T
+// verb, which qualifies them with their package name.
+func (m MinedModel[STATE, EVENT]) GoCode(name string) string {
+	var state STATE
+	var event EVENT
+	types := fmt.Sprintf("%T, %T", state, event)
+
+	counts := make(map[Edge[STATE, EVENT]]int)
+	for _, t := range m.dominant() {
+		counts[Edge[STATE, EVENT]{Src: t.Src, Event: t.Event, Dst: t.Dst}] = t.Count
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("// %s constructs the model inferred from %d traces.\n", name, m.Traces))
+	buf.WriteString(fmt.Sprintf("func %s[FSM_IMPL, ARG any]() *fsm.FSM[%s, FSM_IMPL, ARG] {\n", name, types))
+	buf.WriteString(fmt.Sprintf("\treturn fsm.NewFSM[%s, FSM_IMPL, ARG](%#v, []fsm.EventDesc[%s]{\n", types, m.Initial, types))
+	for _, e := range m.EventDescs() {
+		src := make([]string, len(e.Src))
+		observed := make([]string, len(e.Src))
+		for i, s := range e.Src {
+			src[i] = fmt.Sprintf("%#v", s)
+			observed[i] = fmt.Sprintf("%v: %d", s, counts[Edge[STATE, EVENT]{Src: s, Event: e.Name, Dst: e.Dst}])
+		}
+		buf.WriteString(fmt.Sprintf("\t\t{Name: %#v, Src: []%T{%s}, Dst: %#v}, // %s\n",
+			e.Name, state, strings.Join(src, ", "), e.Dst, strings.Join(observed, ", ")))
+	}
+	buf.WriteString("\t})\n}\n")
+
+	if code, err := format.Source(buf.Bytes()); err == nil {
+		return string(code)
+	}
+	return buf.String()
+}
+
+// Mermaid outputs the model as a Mermaid state diagram, each transition
+// labeled with its event and number of observations. The transitions left
+// out of the model are drawn too, marked as such.
+func (m MinedModel[STATE, EVENT]) Mermaid() string {
+	var buf bytes.Buffer
+	buf.WriteString("stateDiagram-v2\n")
+	buf.WriteString(fmt.Sprintln(`    [*] -->`, m.Initial))
+	for _, t := range m.Transitions {
+		if t.Dominant {
+			buf.WriteString(fmt.Sprintf("    %v --> %v: %v (%d)\n", t.Src, t.Dst, t.Event, t.Count))
+		} else {
+			buf.WriteString(fmt.Sprintf("    %v --> %v: %v (%d, left out)\n", t.Src, t.Dst, t.Event, t.Count))
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMine(t *testing.T) {
+	type S = Step[string, string]
+	traces := [][]S{
+		{{"open", "closed", "open"}, {"close", "open", "closed"}},
+		{{"knock", "closed", "closed"}, {"open", "closed", "open"}},
+		{{"open", "closed", "open"}, {"close", "open", "closed"}, {"open", "closed", "open"}},
+		// A glitch in the logs.
+		{{"close", "open", "open"}},
+	}
+	m := Mine(traces)
+
+	if m.Initial != "closed" || m.Traces != 4 {
+		t.Errorf("unexpected initial state %s and trace count %d", m.Initial, m.Traces)
+	}
+	expected := []MinedTransition[string, string]{
+		{"closed", "knock", "closed", 1, true},
+		{"closed", "open", "open", 4, true},
+		{"open", "close", "closed", 2, true},
+		{"open", "close", "open", 1, false},
+	}
+	if !reflect.DeepEqual(m.Transitions, expected) {
+		t.Errorf("unexpected transitions %+v", m.Transitions)
+	}
+
+	if equivalent, counterexample := Equivalent(NewMinedFSM[string, string, door, any](m), newDoorFSM()); !equivalent {
+		t.Errorf("expected the door model, got counterexample %v", counterexample)
+	}
+
+	code := `// newDoorFSM constructs the model inferred from 4 traces.
+func newDoorFSM[FSM_IMPL, ARG any]() *fsm.FSM[string, string, FSM_IMPL, ARG] {
+	return fsm.NewFSM[string, string, FSM_IMPL, ARG]("closed", []fsm.EventDesc[string, string]{
+		{Name: "close", Src: []string{"open"}, Dst: "closed"},   // open: 2
+		{Name: "knock", Src: []string{"closed"}, Dst: "closed"}, // closed: 1
+		{Name: "open", Src: []string{"closed"}, Dst: "open"},    // closed: 4
+	})
+}
+`
+	if got := m.GoCode("newDoorFSM"); got != code {
+		t.Errorf("unexpected Go code:\n%s", got)
+	}
+
+	mermaid := `stateDiagram-v2
+    [*] --> closed
+    closed --> closed: knock (1)
+    closed --> open: open (4)
+    open --> closed: close (2)
+    open --> open: close (1, left out)
+`
+	if got := m.Mermaid(); got != mermaid {
+		t.Errorf("unexpected Mermaid diagram:\n%s", got)
+	}
+}
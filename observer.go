@@ -0,0 +1,170 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsm
+
+import "time"
+
+// Phase identifies a step of Instance.Event.
+type Phase string
+
+const (
+	// PhaseDispatch is reported when an event is dispatched, before anything
+	// else happens.
+	PhaseDispatch Phase = "dispatch"
+	// PhaseBefore is reported after the before callbacks.
+	PhaseBefore Phase = "before"
+	// PhaseLeave is reported after the leave callbacks.
+	PhaseLeave Phase = "leave"
+	// PhaseCommit is reported after the destination state is stored.
+	PhaseCommit Phase = "commit"
+	// PhaseEnter is reported after the enter callbacks.
+	PhaseEnter Phase = "enter"
+	// PhaseAfter is reported after the after callbacks.
+	PhaseAfter Phase = "after"
+	// PhaseReject is reported when the event is refused without calling any
+	// callback, or when the transition cannot be committed.
+	PhaseReject Phase = "reject"
+	// PhaseCancel is reported when a callback canceled the transition.
+	PhaseCancel Phase = "cancel"
+	// PhasePanic is reported when a callback panicked and the panic was
+	// recovered according to the PanicPolicy of the model.
+	PhasePanic Phase = "panic"
+	// PhaseDone is reported last, with the duration of the whole dispatch.
+	PhaseDone Phase = "done"
+	// PhaseCallback is reported after each callback, within the phase of
+	// the callback. Observation.Callback names the callback.
+	PhaseCallback Phase = "callback"
+)
+
+// Observation describes a phase of a dispatch.
+type Observation[STATE, EVENT comparable] struct {
+	// InstanceID is the ID of the instance the event was dispatched to.
+	InstanceID string
+
+	// Event is the event name.
+	Event EVENT
+
+	// Src is the state the instance was in when the event was dispatched.
+	Src STATE
+
+	// Dst is the destination state, or Src while it is not known yet.
+	Dst STATE
+
+	// Phase is the phase observed.
+	Phase Phase
+
+	// Duration is the time spent in the phase, zero for PhaseDispatch,
+	// PhaseReject and PhaseCancel, and the total time for PhaseDone.
+	Duration time.Duration
+
+	// Callback is the name of the callback of PhaseCallback, like
+	// before_<EVENT> or enter_state.
+	Callback string
+
+	// Err is the error of PhaseReject, PhaseCancel and PhaseDone.
+	Err error
+
+	// Committed is true from PhaseCommit on, once the instance is in Dst.
+	Committed bool
+}
+
+// Observer is notified of every phase of every event dispatched to the
+// instances of a model. It is called synchronously and must be safe for
+// concurrent use.
+type Observer[STATE, EVENT comparable] interface {
+	Observe(o Observation[STATE, EVENT])
+}
+
+// AddObserver adds an observer notified of the events dispatched to all
+// instances of the model.
+func (f *FSM[STATE, EVENT, FSM_IMPL, ARG]) AddObserver(o Observer[STATE, EVENT]) *FSM[STATE, EVENT, FSM_IMPL, ARG] {
+	f.observers = append(f.observers, o)
+	return f
+}
+
+// AddObserver adds an observer notified of the events dispatched to the
+// instance only, after the observers of the model. It must be called before
+// the instance is shared.
+func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) AddObserver(o Observer[STATE, EVENT]) *Instance[STATE, EVENT, FSM_IMPL, ARG] {
+	f.observers = append(f.observers, o)
+	return f
+}
+
+// trace reports the phases of a single dispatch to the observers.
+type trace[STATE, EVENT comparable] struct {
+	observers []Observer[STATE, EVENT]
+	clock     Clock
+	o         Observation[STATE, EVENT]
+	// start is the start of the dispatch, last the end of the previous phase.
+	start, last time.Time
+}
+
+func newTrace[STATE, EVENT comparable](observers []Observer[STATE, EVENT], clock Clock, instanceID string, event EVENT, src STATE) *trace[STATE, EVENT] {
+	t := &trace[STATE, EVENT]{
+		observers: observers,
+		clock:     clock,
+		o:         Observation[STATE, EVENT]{InstanceID: instanceID, Event: event, Src: src, Dst: src},
+	}
+	if len(observers) > 0 {
+		t.start = t.clock.Now()
+		t.last = t.start
+		t.notify(PhaseDispatch, 0, nil)
+	}
+	return t
+}
+
+// phase reports a phase that lasted since the end of the previous one.
+func (t *trace[STATE, EVENT]) phase(p Phase) {
+	if len(t.observers) > 0 {
+		now := t.clock.Now()
+		t.notify(p, now.Sub(t.last), nil)
+		t.last = now
+	}
+}
+
+// point reports an instantaneous phase.
+func (t *trace[STATE, EVENT]) point(p Phase, err error) {
+	if len(t.observers) > 0 {
+		t.notify(p, 0, err)
+	}
+}
+
+// callback calls a callback named name and reports it.
+func (t *trace[STATE, EVENT]) callback(name string, call func()) {
+	if t == nil || len(t.observers) == 0 {
+		call()
+		return
+	}
+	start := t.clock.Now()
+	call()
+	t.o.Callback = name
+	t.notify(PhaseCallback, t.clock.Now().Sub(start), nil)
+	t.o.Callback = ""
+}
+
+// done reports the end of the dispatch.
+func (t *trace[STATE, EVENT]) done(err error) {
+	if len(t.observers) > 0 {
+		t.notify(PhaseDone, t.clock.Now().Sub(t.start), err)
+	}
+}
+
+func (t *trace[STATE, EVENT]) notify(p Phase, d time.Duration, err error) {
+	o := t.o
+	o.Phase, o.Duration, o.Err = p, d, err
+	for _, observer := range t.observers {
+		observer.Observe(o)
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsm
+
+import (
+	"expvar"
+	"fmt"
+	"sync"
+)
+
+// ExpvarObserver is an Observer exposing counters and latency histograms with
+// the expvar package. Its variables are grouped in a map with these keys:
+//
+// - dispatched, rejected, canceled, panicked: counters by event
+//
+// - transitions: counter by transition, keyed "src->dst"
+//
+// - event_latency: histogram of dispatch durations by event
+//
+// - transition_latency: histogram of committed transition durations by
+// transition
+//
+// - phase_latency: histogram of durations by phase
+//
+// - callback_latency: histogram of durations by callback
+type ExpvarObserver[STATE, EVENT comparable] struct {
+	vars *expvar.Map
+
+	dispatched, rejected, canceled, panicked      *expvar.Map
+	transitions                                   *expvar.Map
+	eventLatency, transitionLatency, phaseLatency *expvar.Map
+	callbackLatency                               *expvar.Map
+
+	// mu guards the creation of histograms.
+	mu sync.Mutex
+}
+
+// NewExpvarObserver creates an ExpvarObserver and publishes its variables
+// under name. Like expvar.Publish, it panics if name is already in use.
+func NewExpvarObserver[STATE, EVENT comparable](name string) *ExpvarObserver[STATE, EVENT] {
+	o := &ExpvarObserver[STATE, EVENT]{
+		vars:              expvar.NewMap(name),
+		dispatched:        new(expvar.Map).Init(),
+		rejected:          new(expvar.Map).Init(),
+		canceled:          new(expvar.Map).Init(),
+		panicked:          new(expvar.Map).Init(),
+		transitions:       new(expvar.Map).Init(),
+		eventLatency:      new(expvar.Map).Init(),
+		transitionLatency: new(expvar.Map).Init(),
+		phaseLatency:      new(expvar.Map).Init(),
+		callbackLatency:   new(expvar.Map).Init(),
+	}
+	o.vars.Set("dispatched", o.dispatched)
+	o.vars.Set("rejected", o.rejected)
+	o.vars.Set("canceled", o.canceled)
+	o.vars.Set("panicked", o.panicked)
+	o.vars.Set("transitions", o.transitions)
+	o.vars.Set("event_latency", o.eventLatency)
+	o.vars.Set("transition_latency", o.transitionLatency)
+	o.vars.Set("phase_latency", o.phaseLatency)
+	o.vars.Set("callback_latency", o.callbackLatency)
+	return o
+}
+
+// Vars returns the map holding the variables of the observer.
+func (o *ExpvarObserver[STATE, EVENT]) Vars() *expvar.Map {
+	return o.vars
+}
+
+// Observe implements Observer.
+func (o *ExpvarObserver[STATE, EVENT]) Observe(obs Observation[STATE, EVENT]) {
+	event := fmt.Sprint(obs.Event)
+	switch obs.Phase {
+	case PhaseDispatch:
+		o.dispatched.Add(event, 1)
+	case PhaseReject:
+		o.rejected.Add(event, 1)
+	case PhaseCancel:
+		o.canceled.Add(event, 1)
+	case PhasePanic:
+		o.panicked.Add(event, 1)
+	case PhaseDone:
+		o.histogram(o.eventLatency, event).Observe(obs.Duration)
+		if obs.Committed {
+			transition := fmt.Sprintf("%v->%v", obs.Src, obs.Dst)
+			o.transitions.Add(transition, 1)
+			o.histogram(o.transitionLatency, transition).Observe(obs.Duration)
+		}
+	case PhaseCallback:
+		o.histogram(o.callbackLatency, obs.Callback).Observe(obs.Duration)
+	default:
+		o.histogram(o.phaseLatency, string(obs.Phase)).Observe(obs.Duration)
+	}
+}
+
+// histogram returns the histogram stored in m under key, creating it if
+// needed.
+func (o *ExpvarObserver[STATE, EVENT]) histogram(m *expvar.Map, key string) *Histogram {
+	if h, ok := m.Get(key).(*Histogram); ok {
+		return h
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if h, ok := m.Get(key).(*Histogram); ok {
+		return h
+	}
+	h := NewHistogram()
+	m.Set(key, h)
+	return h
+}
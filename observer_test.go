@@ -0,0 +1,146 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsm
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type observerFunc[STATE, EVENT comparable] func(Observation[STATE, EVENT])
+
+func (f observerFunc[STATE, EVENT]) Observe(o Observation[STATE, EVENT]) { f(o) }
+
+func TestObserverPhases(t *testing.T) {
+	var phases []Phase
+	var callbacks []string
+	f := newDoorFSM().
+		Before("close", func(_ *door, e *Event[string, string, any]) { e.Cancel() }).
+		AddObserver(observerFunc[string, string](func(o Observation[string, string]) {
+			phases = append(phases, o.Phase)
+			if o.Callback != "" {
+				callbacks = append(callbacks, o.Callback)
+			}
+		}))
+	inst := f.NewInstance()
+
+	tests := []struct {
+		event  string
+		phases []Phase
+	}{
+		{"open", []Phase{PhaseDispatch, PhaseBefore, PhaseLeave, PhaseCommit, PhaseEnter, PhaseAfter, PhaseDone}},
+		{"close", []Phase{PhaseDispatch, PhaseCallback, PhaseBefore, PhaseCancel, PhaseDone}},
+		{"knock", []Phase{PhaseDispatch, PhaseReject, PhaseDone}},
+		{"lock", []Phase{PhaseDispatch, PhaseReject, PhaseDone}},
+	}
+	for _, test := range tests {
+		phases = nil
+		inst.Event(test.event)
+		if !reflect.DeepEqual(phases, test.phases) {
+			t.Errorf("event %s: expected phases %v, got %v", test.event, test.phases, phases)
+		}
+	}
+
+	if !reflect.DeepEqual(callbacks, []string{"before_close"}) {
+		t.Errorf("expected callback before_close to be observed, got %v", callbacks)
+	}
+
+	phases = nil
+	inst.EventFrom("closed", "close")
+	if !reflect.DeepEqual(phases, []Phase{PhaseDispatch, PhaseReject, PhaseDone}) {
+		t.Errorf("expected precondition failure to be rejected, got %v", phases)
+	}
+}
+
+func TestInstanceObserver(t *testing.T) {
+	var observed []string
+	observer := func(name string) Observer[string, string] {
+		return observerFunc[string, string](func(o Observation[string, string]) {
+			if o.Phase == PhaseDispatch {
+				observed = append(observed, name+" "+o.InstanceID)
+			}
+		})
+	}
+	f := newDoorFSM().AddObserver(observer("model"))
+	f.NewInstance().SetID("front").AddObserver(observer("instance")).Event("open")
+	f.NewInstance().SetID("back").Event("open")
+
+	if !reflect.DeepEqual(observed, []string{"model front", "instance front", "model back"}) {
+		t.Errorf("expected the instance observer to follow the model ones, got %v", observed)
+	}
+}
+
+func TestExpvarObserver(t *testing.T) {
+	// expvar names cannot be reused, and the test may run several times in a
+	// process with -count.
+	name := fmt.Sprintf("fsm_test_door_%d", time.Now().UnixNano())
+	o := NewExpvarObserver[string, string](name)
+	inst := newDoorFSM().
+		OnEnter("open", func(_ *door, _ *Event[string, string, any]) {}).
+		AddObserver(o).
+		NewInstance()
+	inst.Event("open")
+	inst.Event("open")
+	inst.Event("close")
+	inst.Event("lock")
+
+	if expvar.Get(name) != o.Vars() {
+		t.Error("expected variables to be published")
+	}
+	var vars struct {
+		Dispatched        map[string]int
+		Rejected          map[string]int
+		Transitions       map[string]int
+		TransitionLatency map[string]struct{ Count int } `json:"transition_latency"`
+		PhaseLatency      map[string]struct{ Count int } `json:"phase_latency"`
+		CallbackLatency   map[string]struct{ Count int } `json:"callback_latency"`
+	}
+	if err := json.Unmarshal([]byte(o.Vars().String()), &vars); err != nil {
+		t.Fatalf("expected variables to be valid JSON: %v", err)
+	}
+	if vars.Dispatched["open"] != 2 || vars.Dispatched["lock"] != 1 {
+		t.Errorf("unexpected dispatch counters %v", vars.Dispatched)
+	}
+	if vars.Rejected["open"] != 1 || vars.Rejected["lock"] != 1 {
+		t.Errorf("unexpected rejection counters %v", vars.Rejected)
+	}
+	if vars.Transitions["closed->open"] != 1 || vars.TransitionLatency["open->closed"].Count != 1 {
+		t.Errorf("unexpected transition counters %v %v", vars.Transitions, vars.TransitionLatency)
+	}
+	if vars.PhaseLatency["commit"].Count != 2 {
+		t.Errorf("unexpected phase latencies %v", vars.PhaseLatency)
+	}
+	if vars.CallbackLatency["enter_open"].Count != 1 || len(vars.CallbackLatency) != 1 {
+		t.Errorf("unexpected callback latencies %v", vars.CallbackLatency)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram(time.Millisecond, time.Second)
+	h.Observe(time.Millisecond)
+	h.Observe(time.Minute)
+	h.Observe(time.Minute)
+	buckets := h.Buckets()
+	if h.Count() != 3 || h.Sum() != 2*time.Minute+time.Millisecond {
+		t.Errorf("unexpected count %d and sum %s", h.Count(), h.Sum())
+	}
+	if buckets[0].Count != 1 || buckets[1].Count != 0 || buckets[2].Count != 2 {
+		t.Errorf("unexpected buckets %+v", buckets)
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsm
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+)
+
+// ErrCallbackPanic matches a CallbackPanicError with errors.Is.
+var ErrCallbackPanic = errors.New("callback panicked")
+
+// PanicPolicy decides what happens when a callback panics.
+type PanicPolicy int
+
+const (
+	// PanicPropagate lets the panic unwind through Instance.Event. It is the
+	// default.
+	PanicPropagate PanicPolicy = iota
+	// PanicRevert recovers the panic and returns a CallbackPanicError. If the
+	// destination state was already stored, the instance is moved back to
+	// the source state.
+	PanicRevert
+	// PanicKeep recovers the panic and returns a CallbackPanicError, leaving
+	// the instance in the state it reached when the callback panicked.
+	PanicKeep
+)
+
+// SetPanicPolicy sets the policy applied when a callback panics.
+func (f *FSM[STATE, EVENT, FSM_IMPL, ARG]) SetPanicPolicy(policy PanicPolicy) *FSM[STATE, EVENT, FSM_IMPL, ARG] {
+	f.panicPolicy = policy
+	return f
+}
+
+// CallbackPanicError is returned by Instance.Event when a callback panicked
+// and the PanicPolicy of the model recovers panics.
+type CallbackPanicError struct {
+	// Hook is the name of the callback, e.g. enter_open.
+	Hook string
+	// Value is the value the callback panicked with.
+	Value any
+	// Stack is the stack trace of the goroutine when the callback panicked.
+	Stack []byte
+	// Reverted is true if the instance was moved back to the source state.
+	Reverted bool
+
+	// InstanceID is the ID of the instance the event was dispatched to.
+	InstanceID string
+	// Phase is the phase of the callback.
+	Phase Phase
+}
+
+func (e CallbackPanicError) Error() string {
+	return fmt.Sprintf("callback %s panicked: %v", e.Hook, e.Value)
+}
+
+// Is returns true for ErrCallbackPanic.
+func (e CallbackPanicError) Is(target error) bool {
+	return target == ErrCallbackPanic
+}
+
+// Unwrap returns the value the callback panicked with if it is an error.
+func (e CallbackPanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// callHook calls the hook h of phase. Unless panics propagate, a panic of the
+// callback is turned into a panic with a CallbackPanicError, recovered by
+// recoverCallback.
+func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) callHook(h hook[STATE, EVENT, FSM_IMPL, ARG], e *Event[STATE, EVENT, ARG], phase Phase) {
+	if f.panicPolicy != PanicPropagate {
+		defer func() {
+			if v := recover(); v != nil {
+				if _, ok := v.(CallbackPanicError); ok {
+					panic(v)
+				}
+				panic(CallbackPanicError{Hook: h.name(), Value: v, Stack: debug.Stack(), InstanceID: f.id, Phase: phase})
+			}
+		}()
+	}
+	e.trace.callback(h.name(), func() { h.fn(f.Self, e) })
+}
+
+// recoverCallback is deferred by the functions calling hooks. It recovers a
+// CallbackPanicError raised by callHook, stores it in err and, with
+// PanicRevert, moves the instance back from the snapshot next it committed
+// to the state of s. Other panics are propagated.
+func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) recoverCallback(s *snapshot[STATE], next **snapshot[STATE], committed *bool, err *error, tr *trace[STATE, EVENT]) {
+	if f.panicPolicy == PanicPropagate {
+		return
+	}
+	v := recover()
+	if v == nil {
+		return
+	}
+	perr, ok := v.(CallbackPanicError)
+	if !ok {
+		panic(v)
+	}
+	*committed = *next != nil
+	if *committed && f.panicPolicy == PanicRevert {
+		if f.swap(*next, s.state, (*next).version+1) != nil {
+			perr.Reverted = true
+			*committed = false
+		}
+	}
+	*err = perr
+	if tr != nil {
+		tr.o.Committed = *committed
+		tr.point(PhasePanic, perr)
+	}
+}
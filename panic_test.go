@@ -0,0 +1,99 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsm
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func panicking(v any) Callback[string, string, door, any] {
+	return func(_ *door, _ *Event[string, string, any]) { panic(v) }
+}
+
+func TestPanicPropagatesByDefault(t *testing.T) {
+	inst := newDoorFSM().OnEnter("open", panicking("boom")).NewInstance()
+	defer func() {
+		if v := recover(); v != "boom" {
+			t.Errorf("expected panic to propagate, got %v", v)
+		}
+	}()
+	inst.Event("open")
+}
+
+func TestPanicRevert(t *testing.T) {
+	inst := newDoorFSM().
+		SetPanicPolicy(PanicRevert).
+		SetHistorySize(1).
+		OnEnter("open", panicking(io.EOF)).
+		NewInstance()
+
+	err := inst.Event("open")
+	var perr CallbackPanicError
+	if !errors.As(err, &perr) || !errors.Is(err, ErrCallbackPanic) || !errors.Is(err, io.EOF) {
+		t.Fatalf("expected CallbackPanicError wrapping io.EOF, got %v", err)
+	}
+	if perr.Hook != "enter_open" || perr.Phase != PhaseEnter || !perr.Reverted || len(perr.Stack) == 0 {
+		t.Errorf("unexpected error %+v", perr)
+	}
+	if inst.Current() != "closed" || inst.Version() != 2 {
+		t.Errorf("expected revert to closed at version 2, got %s at %d", inst.Current(), inst.Version())
+	}
+	if h := inst.History(); h[0].Committed {
+		t.Error("expected reverted transition not to be committed")
+	}
+
+	// The instance is still usable, and a panic before the commit leaves
+	// the state alone.
+	inst = newDoorFSM().SetPanicPolicy(PanicRevert).Before("open", panicking("boom")).NewInstance()
+	err = inst.Event("open")
+	if !errors.As(err, &perr) || perr.Hook != "before_open" || perr.Reverted || inst.Current() != "closed" {
+		t.Errorf("expected panic before commit to leave state, got %v in %s", err, inst.Current())
+	}
+	if err := inst.Event("knock"); err == nil || errors.Is(err, ErrCallbackPanic) {
+		t.Errorf("expected no transition, got %v", err)
+	}
+}
+
+func TestPanicKeep(t *testing.T) {
+	var after bool
+	inst := newDoorFSM().
+		SetPanicPolicy(PanicKeep).
+		SetHistorySize(1).
+		OnEnter("open", panicking("boom")).
+		After("open", func(_ *door, _ *Event[string, string, any]) { after = true }).
+		NewInstance()
+
+	err := inst.Event("open")
+	var perr CallbackPanicError
+	if !errors.As(err, &perr) || perr.Reverted || perr.Value != "boom" {
+		t.Fatalf("expected CallbackPanicError, got %v", err)
+	}
+	if inst.Current() != "open" || after {
+		t.Errorf("expected state open without after callbacks, got %s, after %v", inst.Current(), after)
+	}
+	if h := inst.History(); !h[0].Committed || h[0].Err == nil {
+		t.Errorf("expected committed transition with error, got %+v", h[0])
+	}
+}
+
+func TestSimulateRecoversPanic(t *testing.T) {
+	inst := newDoorFSM().SetPanicPolicy(PanicRevert).OnLeave("closed", panicking("boom")).DryRunSafe("leave_closed").NewInstance()
+	sim := inst.Simulate("open")
+	if !errors.Is(sim.Err, ErrCallbackPanic) || inst.Current() != "closed" {
+		t.Errorf("expected recovered panic, got %v", sim.Err)
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsm
+
+// Simulation is the outcome Instance.Simulate predicts for an event.
+type Simulation[STATE, EVENT comparable] struct {
+	// Event is the event name.
+	Event EVENT
+
+	// Src is the current state of the instance.
+	Src STATE
+
+	// Dst is the destination state, or Src if the event would be rejected.
+	Dst STATE
+
+	// Err is the error Instance.Event would return, nil if the transition
+	// would succeed.
+	Err error
+
+	// CanceledBy is the name of the callback that would cancel the
+	// transition, like before_<EVENT> or leave_state.
+	CanceledBy string
+
+	// Callbacks are the names of the callbacks that would be called, in
+	// order, up to the one canceling the transition.
+	Callbacks []string
+
+	// Unevaluated are the names of the before and leave callbacks that were
+	// not called because they are not marked with FSM.DryRunSafe. Any of
+	// them may still cancel the transition.
+	Unevaluated []string
+}
+
+// DryRunSafe marks the callbacks, named like before_<EVENT> or leave_state,
+// that have no side effects and can be called by Instance.Simulate.
+func (f *FSM[STATE, EVENT, FSM_IMPL, ARG]) DryRunSafe(callbacks ...string) *FSM[STATE, EVENT, FSM_IMPL, ARG] {
+	for _, name := range callbacks {
+		f.dryRunSafe[name] = true
+	}
+	return f
+}
+
+// Simulate predicts the outcome of dispatching event to the instance without
+// changing its state. It is meant to explain why an event is not possible,
+// which Can cannot do when callbacks cancel transitions.
+//
+// The before and leave callbacks marked with FSM.DryRunSafe are called with
+// Event.DryRun set, since they are the ones that may cancel the transition.
+// The other before and leave callbacks are assumed not to cancel it and are
+// reported in Simulation.Unevaluated. The enter and after callbacks are only
+// listed.
+func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) Simulate(event EVENT, args ...ARG) (sim Simulation[STATE, EVENT]) {
+	f.eventMu.Lock()
+	defer f.eventMu.Unlock()
+
+	s := f.load()
+	src := s.state
+	sim = Simulation[STATE, EVENT]{Event: event, Src: src, Dst: src}
+	var next *snapshot[STATE]
+	var committed bool
+	defer f.recoverCallback(s, &next, &committed, &sim.Err, nil)
+
+	dst, ok := f.transitions[eKey[STATE, EVENT]{event, src}]
+	if !ok {
+		sim.Err = f.rejection(event, src)
+		return sim
+	}
+
+	sim.Dst = dst
+	e := &Event[STATE, EVENT, ARG]{Event: event, Src: src, Dst: dst, Args: args, DryRun: true}
+	if f.simulateCancelable(&sim, f.beforeHooks(event), e, PhaseBefore) {
+		return sim
+	}
+	if src == dst {
+		f.simulateListed(&sim, f.afterHooks(event))
+		sim.Err = NoTransitionError{Err: e.Err, InstanceID: f.id, Phase: PhaseCommit}
+		return sim
+	}
+	if f.simulateCancelable(&sim, f.leaveHooks(src), e, PhaseLeave) {
+		return sim
+	}
+	f.simulateListed(&sim, f.enterHooks(dst))
+	f.simulateListed(&sim, f.afterHooks(event))
+	return sim
+}
+
+// simulateCancelable calls the dry run safe hooks until one cancels e and
+// records all of them in sim. It returns true if the transition was canceled.
+func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) simulateCancelable(sim *Simulation[STATE, EVENT], hooks []hook[STATE, EVENT, FSM_IMPL, ARG], e *Event[STATE, EVENT, ARG], phase Phase) bool {
+	for _, h := range hooks {
+		sim.Callbacks = append(sim.Callbacks, h.name())
+		if !f.dryRunSafe[h.name()] {
+			sim.Unevaluated = append(sim.Unevaluated, h.name())
+			continue
+		}
+		f.callHook(h, e, phase)
+		if e.canceled {
+			sim.CanceledBy, sim.Err = h.name(), CanceledError{Err: e.Err, InstanceID: f.id, Phase: phase}
+			return true
+		}
+	}
+	return false
+}
+
+// simulateListed records hooks in sim without calling them.
+func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) simulateListed(sim *Simulation[STATE, EVENT], hooks []hook[STATE, EVENT, FSM_IMPL, ARG]) {
+	for _, h := range hooks {
+		sim.Callbacks = append(sim.Callbacks, h.name())
+	}
+}
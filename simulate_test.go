@@ -0,0 +1,65 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsm
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSimulate(t *testing.T) {
+	locked := errors.New("locked")
+	var sideEffects int
+	noop := func(_ *door, _ *Event[string, string, any]) { sideEffects++ }
+	inst := newDoorFSM().
+		BeforeAny(noop).
+		OnLeave("open", func(_ *door, e *Event[string, string, any]) {
+			if len(e.Args) > 0 && e.Args[0] == "locked" {
+				e.Cancel(locked)
+			}
+		}).
+		OnEnter("open", noop).
+		AfterAny(noop).
+		DryRunSafe("leave_open").
+		NewInstance()
+
+	sim := inst.Simulate("open")
+	if sim.Err != nil || sim.Dst != "open" || !inst.Is("closed") {
+		t.Errorf("expected open to be possible without committing, got %+v in %s", sim, inst.Current())
+	}
+	if !reflect.DeepEqual(sim.Callbacks, []string{"before_event", "enter_open", "after_event"}) {
+		t.Errorf("unexpected callbacks %v", sim.Callbacks)
+	}
+	if sideEffects != 0 {
+		t.Error("expected simulation to have no side effect")
+	}
+	if !reflect.DeepEqual(sim.Unevaluated, []string{"before_event"}) {
+		t.Errorf("expected before_event to be unevaluated, got %v", sim.Unevaluated)
+	}
+
+	inst.Event("open")
+	sim = inst.Simulate("close", "locked")
+	if !errors.Is(sim.Err.(CanceledError).Err, locked) || sim.CanceledBy != "leave_open" {
+		t.Errorf("expected leave_open to cancel, got %+v", sim)
+	}
+	if !reflect.DeepEqual(sim.Callbacks, []string{"before_event", "leave_open"}) {
+		t.Errorf("unexpected callbacks %v", sim.Callbacks)
+	}
+
+	if _, ok := inst.Simulate("knock").Err.(InvalidEventError[string, string]); !ok {
+		t.Error("expected knock to be invalid in open")
+	}
+}
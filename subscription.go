@@ -0,0 +1,114 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsm
+
+import "sync"
+
+// SubscriptionPolicy decides what happens to a committed transition when the
+// channel of a subscriber is full.
+type SubscriptionPolicy int
+
+const (
+	// DropPolicy drops the transition for that subscriber. It is the default
+	// and never delays the transition.
+	DropPolicy SubscriptionPolicy = iota
+	// BlockPolicy waits until the subscriber receives the transition or
+	// unsubscribes. The transition does not return before.
+	BlockPolicy
+)
+
+// subscribers is a set of channels receiving committed transitions.
+type subscribers[STATE, EVENT comparable] struct {
+	mu   sync.RWMutex
+	subs map[*subscriber[STATE, EVENT]]struct{}
+}
+
+type subscriber[STATE, EVENT comparable] struct {
+	ch     chan Transition[STATE, EVENT]
+	done   chan struct{}
+	policy SubscriptionPolicy
+	once   sync.Once
+}
+
+// add registers a new subscriber and returns its channel along with the
+// function removing it.
+func (s *subscribers[STATE, EVENT]) add(buffer int, policy SubscriptionPolicy) (<-chan Transition[STATE, EVENT], func()) {
+	sub := &subscriber[STATE, EVENT]{
+		ch:     make(chan Transition[STATE, EVENT], buffer),
+		done:   make(chan struct{}),
+		policy: policy,
+	}
+	s.mu.Lock()
+	if s.subs == nil {
+		s.subs = make(map[*subscriber[STATE, EVENT]]struct{})
+	}
+	s.subs[sub] = struct{}{}
+	s.mu.Unlock()
+
+	return sub.ch, func() {
+		sub.once.Do(func() {
+			// Release a publisher blocked on the channel before waiting
+			// for the lock it holds.
+			close(sub.done)
+			s.mu.Lock()
+			delete(s.subs, sub)
+			s.mu.Unlock()
+			close(sub.ch)
+		})
+	}
+}
+
+// publish delivers t to every subscriber according to its policy.
+func (s *subscribers[STATE, EVENT]) publish(t Transition[STATE, EVENT]) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for sub := range s.subs {
+		if sub.policy == BlockPolicy {
+			select {
+			case sub.ch <- t:
+			case <-sub.done:
+			}
+			continue
+		}
+		select {
+		case sub.ch <- t:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel receiving every transition committed by any
+// instance of the model, and a function that unsubscribes and closes the
+// channel. Transition.InstanceID tells the instances apart.
+func (f *FSM[STATE, EVENT, FSM_IMPL, ARG]) Subscribe(buffer int) (<-chan Transition[STATE, EVENT], func()) {
+	return f.subscribers.add(buffer, f.subscriptionPolicy)
+}
+
+// SetSubscriptionPolicy sets the policy applied to subscriptions made
+// afterwards, on the model and on its instances.
+func (f *FSM[STATE, EVENT, FSM_IMPL, ARG]) SetSubscriptionPolicy(policy SubscriptionPolicy) *FSM[STATE, EVENT, FSM_IMPL, ARG] {
+	f.subscriptionPolicy = policy
+	return f
+}
+
+// Subscribe returns a channel receiving every transition committed by the
+// instance, and a function that unsubscribes and closes the channel.
+//
+// Transitions are delivered after the enter and after callbacks have run.
+// Whether a slow subscriber misses transitions or delays them is decided by
+// the SubscriptionPolicy of the model.
+func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) Subscribe(buffer int) (<-chan Transition[STATE, EVENT], func()) {
+	return f.subscribers.add(buffer, f.subscriptionPolicy)
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsm
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSubscribe(t *testing.T) {
+	f := newDoorFSM()
+	all, cancelAll := f.Subscribe(10)
+	defer cancelAll()
+
+	inst := f.NewInstance().SetID("front")
+	ch, cancel := inst.Subscribe(10)
+	inst.Event("open")
+	inst.Event("open")
+	inst.Event("close")
+	f.NewInstance().Event("open")
+	cancel()
+
+	var got []Transition[string, string]
+	for tr := range ch {
+		got = append(got, tr)
+	}
+	if len(got) != 2 || got[0].Dst != "open" || got[1].Dst != "closed" || got[0].InstanceID != "front" {
+		t.Errorf("expected only committed transitions of the instance, got %+v", got)
+	}
+
+	if n := len(all); n != 3 {
+		t.Errorf("expected model subscription to receive 3 transitions, got %d", n)
+	}
+	if tr := <-all; tr.InstanceID != "front" {
+		t.Errorf("expected transition of front, got %q", tr.InstanceID)
+	}
+}
+
+func TestSubscribeDropPolicy(t *testing.T) {
+	inst := newDoorFSM().NewInstance()
+	ch, cancel := inst.Subscribe(1)
+	defer cancel()
+	inst.Event("open")
+	inst.Event("close")
+	if tr := <-ch; tr.Event != "open" {
+		t.Errorf("expected first transition to be kept, got %+v", tr)
+	}
+	select {
+	case tr := <-ch:
+		t.Errorf("expected second transition to be dropped, got %+v", tr)
+	default:
+	}
+}
+
+func TestSubscribeBlockPolicy(t *testing.T) {
+	inst := newDoorFSM().SetSubscriptionPolicy(BlockPolicy).NewInstance()
+	ch, cancel := inst.Subscribe(0)
+
+	done := make(chan struct{})
+	go func() {
+		inst.Event("open")
+		inst.Event("close")
+		close(done)
+	}()
+	if tr := <-ch; tr.Event != "open" {
+		t.Errorf("expected open, got %+v", tr)
+	}
+	select {
+	case <-done:
+		t.Fatal("expected dispatch to block on the subscriber")
+	case <-time.After(10 * time.Millisecond):
+	}
+	cancel()
+	<-done
+}
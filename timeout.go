@@ -0,0 +1,75 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsm
+
+import "time"
+
+type timeout[EVENT comparable] struct {
+	after time.Duration
+	event EVENT
+}
+
+// Timeout declares that event is dispatched to an instance that stayed in
+// state for the duration d. Entering the state, including with SetState or as
+// the initial state, arms a timer with the clock of the instance, and leaving
+// it disarms the timer.
+//
+// The event is dispatched without arguments from the goroutine of the timer.
+// Its outcome can be observed through the history, subscriptions, observers
+// and the logger, like any other event.
+func (f *FSM[STATE, EVENT, FSM_IMPL, ARG]) Timeout(state STATE, d time.Duration, event EVENT) *FSM[STATE, EVENT, FSM_IMPL, ARG] {
+	f.timeouts[state] = timeout[EVENT]{d, event}
+	return f
+}
+
+// rearm arms the timer of the current stay, if its state has a timeout and no
+// timer is armed for it yet, and disarms the timer of a previous stay.
+func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) rearm() {
+	f.timerMu.Lock()
+	defer f.timerMu.Unlock()
+
+	s := f.load()
+	if f.armed != nil && f.armed.stay == s.stay {
+		return
+	}
+	if f.timer != nil {
+		f.timer.Stop()
+		f.timer = nil
+	}
+	f.armed = s
+
+	t, ok := f.timeouts[s.state]
+	if !ok {
+		return
+	}
+	f.timer = f.clock.AfterFunc(t.after-f.clock.Now().Sub(s.entered), func() {
+		if f.load().stay != s.stay {
+			return
+		}
+		f.dispatch(func(current *snapshot[STATE]) error {
+			if current.stay != s.stay {
+				return ConflictError[STATE]{
+					ExpectedState:   s.state,
+					ExpectedVersion: s.version,
+					State:           current.state,
+					Version:         current.version,
+					InstanceID:      f.id,
+					Phase:           PhaseDispatch,
+				}
+			}
+			return nil
+		}, t.event, nil, nil)
+	})
+}
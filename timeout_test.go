@@ -0,0 +1,96 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsm_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/maozhixiang/fsm"
+	"github.com/maozhixiang/fsm/fsmtest"
+)
+
+var epoch = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestTimeout(t *testing.T) {
+	clock := fsmtest.NewManualClock(epoch)
+	inst := fsm.NewDoorFSM().SetClock(clock).Timeout("open", 15*time.Minute, "close").NewInstance()
+	inst.Event("open")
+
+	clock.Advance(10 * time.Minute)
+	inst.SetState("open")
+	if !inst.Is("open") {
+		t.Fatal("expected door to be open")
+	}
+	clock.Advance(5 * time.Minute)
+	if !inst.Is("closed") {
+		t.Errorf("expected door to close after 15m open, got %s", inst.Current())
+	}
+}
+
+func TestTimeoutDisarmedOnLeave(t *testing.T) {
+	clock := fsmtest.NewManualClock(epoch)
+	f := fsm.NewDoorFSM().SetClock(clock).Timeout("open", 15*time.Minute, "close")
+	inst := f.NewInstance()
+	inst.Event("open")
+	clock.Advance(10 * time.Minute)
+	inst.Event("close")
+	inst.SetState("open")
+	clock.Advance(10 * time.Minute)
+	if !inst.Is("open") {
+		t.Errorf("expected the first stay's timer to be disarmed, got %s", inst.Current())
+	}
+	clock.Advance(5 * time.Minute)
+	if !inst.Is("closed") {
+		t.Errorf("expected the second stay to time out, got %s", inst.Current())
+	}
+
+	restored := f.NewInstance()
+	restored.Restore("open", 3)
+	clock.Advance(15 * time.Minute)
+	if !restored.Is("closed") {
+		t.Errorf("expected restored instance to time out, got %s", restored.Current())
+	}
+}
+
+func TestTimeoutWithSystemClock(t *testing.T) {
+	inst := fsm.NewDoorFSM().Timeout("open", time.Millisecond, "close").NewInstance()
+	inst.Event("open")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if state, _ := inst.WaitFor(ctx, "closed"); state != "closed" {
+		t.Errorf("expected door to close, got %s", state)
+	}
+}
+
+func TestInstanceClock(t *testing.T) {
+	modelClock, instClock := fsmtest.NewManualClock(epoch), fsmtest.NewManualClock(epoch.Add(time.Hour))
+	f := fsm.NewDoorFSM().SetClock(modelClock).Timeout("open", 15*time.Minute, "close").SetHistorySize(1)
+	inst := f.NewInstance().SetClock(instClock)
+	inst.Event("open")
+	if h := inst.History(); !h[0].Time.Equal(instClock.Now()) {
+		t.Errorf("expected history to use the instance clock, got %s", h[0].Time)
+	}
+
+	modelClock.Advance(15 * time.Minute)
+	if !inst.Is("open") {
+		t.Error("expected the model clock not to fire the timeout of the instance")
+	}
+	instClock.Advance(15 * time.Minute)
+	if !inst.Is("closed") {
+		t.Errorf("expected the instance clock to fire the timeout, got %s", inst.Current())
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsm
+
+// TypedEvent is an event of a model carrying a payload of its own type P,
+// independently of the ARG type of the model.
+//
+// It has to be created with DefineEvent. The transitions of the event are
+// declared on the model as usual.
+type TypedEvent[P any, STATE, EVENT comparable, FSM_IMPL, ARG any] struct {
+	fsm  *FSM[STATE, EVENT, FSM_IMPL, ARG]
+	name EVENT
+}
+
+// TypedCallback is a callback of a TypedEvent. It receives the payload the
+// event was fired with.
+type TypedCallback[P any, STATE, EVENT comparable, FSM_IMPL, ARG any] func(*FSM_IMPL, *Event[STATE, EVENT, ARG], P)
+
+// DefineEvent returns a handle on the event name of the model f, whose
+// payload is of type P. The other type parameters are inferred from f:
+//
+//	checkout := fsm.DefineEvent[Order](orderFSM, "checkout")
+func DefineEvent[P any, STATE, EVENT comparable, FSM_IMPL, ARG any](f *FSM[STATE, EVENT, FSM_IMPL, ARG], name EVENT) *TypedEvent[P, STATE, EVENT, FSM_IMPL, ARG] {
+	return &TypedEvent[P, STATE, EVENT, FSM_IMPL, ARG]{fsm: f, name: name}
+}
+
+// Name returns the event name.
+func (t *TypedEvent[P, STATE, EVENT, FSM_IMPL, ARG]) Name() EVENT {
+	return t.name
+}
+
+// Fire dispatches the event to the instance with the payload, like
+// Instance.Event. If the payload is also an ARG, it is passed as the only
+// argument too, so that untyped callbacks see it in Event.Args.
+func (t *TypedEvent[P, STATE, EVENT, FSM_IMPL, ARG]) Fire(inst *Instance[STATE, EVENT, FSM_IMPL, ARG], payload P) error {
+	var args []ARG
+	if arg, ok := any(payload).(ARG); ok {
+		args = []ARG{arg}
+	}
+	return inst.dispatch(nil, t.name, args, payload)
+}
+
+// Payload returns the payload of e. It is the payload given to Fire or, for
+// an event dispatched with Instance.Event, the first argument if it is a P.
+func (t *TypedEvent[P, STATE, EVENT, FSM_IMPL, ARG]) Payload(e *Event[STATE, EVENT, ARG]) (P, bool) {
+	if p, ok := e.payload.(P); ok {
+		return p, true
+	}
+	if len(e.Args) > 0 {
+		if p, ok := any(e.Args[0]).(P); ok {
+			return p, true
+		}
+	}
+	var zero P
+	return zero, false
+}
+
+// Before sets the before callback of the event, see FSM.Before. The callback
+// receives the zero value of P if the event carries no payload of that type.
+func (t *TypedEvent[P, STATE, EVENT, FSM_IMPL, ARG]) Before(cb TypedCallback[P, STATE, EVENT, FSM_IMPL, ARG]) *TypedEvent[P, STATE, EVENT, FSM_IMPL, ARG] {
+	t.fsm.Before(t.name, t.callback(cb))
+	return t
+}
+
+// After sets the after callback of the event, see FSM.After. The callback
+// receives the zero value of P if the event carries no payload of that type.
+func (t *TypedEvent[P, STATE, EVENT, FSM_IMPL, ARG]) After(cb TypedCallback[P, STATE, EVENT, FSM_IMPL, ARG]) *TypedEvent[P, STATE, EVENT, FSM_IMPL, ARG] {
+	t.fsm.After(t.name, t.callback(cb))
+	return t
+}
+
+func (t *TypedEvent[P, STATE, EVENT, FSM_IMPL, ARG]) callback(cb TypedCallback[P, STATE, EVENT, FSM_IMPL, ARG]) Callback[STATE, EVENT, FSM_IMPL, ARG] {
+	return func(impl *FSM_IMPL, e *Event[STATE, EVENT, ARG]) {
+		p, _ := t.Payload(e)
+		cb(impl, e, p)
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsm
+
+import "testing"
+
+type order struct {
+	paid   int
+	reason string
+}
+
+type payment struct{ Amount int }
+type refusal struct{ Reason string }
+
+func TestTypedEvent(t *testing.T) {
+	f := NewFSM[string, string, order, any]("pending", []EventDesc[string, string]{
+		{Name: "pay", Src: []string{"pending"}, Dst: "paid"},
+		{Name: "refuse", Src: []string{"pending"}, Dst: "refused"},
+	})
+	pay := DefineEvent[payment](f, "pay").
+		Before(func(o *order, e *Event[string, string, any], p payment) {
+			if p.Amount <= 0 {
+				e.Cancel()
+			}
+		}).
+		After(func(o *order, e *Event[string, string, any], p payment) { o.paid = p.Amount })
+	refuse := DefineEvent[refusal](f, "refuse").
+		After(func(o *order, e *Event[string, string, any], r refusal) { o.reason = r.Reason })
+
+	inst := f.NewInstance()
+	if _, ok := pay.Fire(inst, payment{}).(CanceledError); !ok {
+		t.Error("expected payment without amount to be canceled")
+	}
+	if err := pay.Fire(inst, payment{Amount: 42}); err != nil || inst.Self.paid != 42 {
+		t.Errorf("expected payment of 42, got %v with %d", err, inst.Self.paid)
+	}
+
+	// The payload is an argument too when ARG allows it, and the other way
+	// around.
+	inst = f.NewInstance()
+	if err := inst.Event("refuse", refusal{"out of stock"}); err != nil || inst.Self.reason != "out of stock" {
+		t.Errorf("expected refusal through Instance.Event, got %v with %q", err, inst.Self.reason)
+	}
+	if refuse.Name() != "refuse" {
+		t.Errorf("unexpected name %s", refuse.Name())
+	}
+}
+
+func TestTypedEventPayloadIndependentOfARG(t *testing.T) {
+	f := NewFSM[string, string, order, string]("pending", []EventDesc[string, string]{
+		{Name: "pay", Src: []string{"pending"}, Dst: "paid"},
+	})
+	var args []string
+	pay := DefineEvent[payment](f, "pay").
+		After(func(o *order, e *Event[string, string, string], p payment) {
+			o.paid, args = p.Amount, e.Args
+		})
+	inst := f.NewInstance()
+	if err := pay.Fire(inst, payment{Amount: 7}); err != nil || inst.Self.paid != 7 || len(args) != 0 {
+		t.Errorf("expected payment of 7 without args, got %v with %d and %v", err, inst.Self.paid, args)
+	}
+}
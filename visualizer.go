@@ -34,6 +34,23 @@ func (fsm *FSM[STATE, EVENT, FSM_IMPL, ARG]) VisualizeWithType(visualizeType Vis
 	}
 }
 
+// VisualizeHighlighted is like VisualizeWithType but draws the edges in
+// highlight with their color, for example the cycles of a CycleReport. Edges
+// cannot be styled in a mermaid state diagram, so MERMAID and
+// MermaidStateDiagram are refused.
+func (fsm *FSM[STATE, EVENT, FSM_IMPL, ARG]) VisualizeHighlighted(visualizeType VisualizeType, current STATE, highlight map[Edge[STATE, EVENT]]string) (string, error) {
+	switch visualizeType {
+	case GRAPHVIZ:
+		return fsm.visualizeGraphviz(current, highlight), nil
+	case MermaidFlowChart:
+		return fsm.visualizeForMermaidAsFlowChart(current, highlight), nil
+	case MERMAID, MermaidStateDiagram:
+		return "", fmt.Errorf("edge highlighting unsupported by VisualizeType: %s", visualizeType)
+	default:
+		return "", fmt.Errorf("unknown VisualizeType: %s", visualizeType)
+	}
+}
+
 func (fsm *Instance[STATE, EVENT, FSM_IMPL, ARG]) VisualizeWithType(visualizeType VisualizeType) (string, error) {
 	return fsm.FSM.VisualizeWithType(visualizeType, fsm.Current())
 }
@@ -7,6 +7,12 @@ import (
 
 // Visualize outputs a visualization of a FSM in Graphviz format.
 func (fsm *FSM[STATE, EVENT, FSM_IMPL, ARG]) Visualize(current STATE) string {
+	return fsm.visualizeGraphviz(current, nil)
+}
+
+// visualizeGraphviz outputs a visualization of a FSM in Graphviz format,
+// drawing the edges in highlight with their color.
+func (fsm *FSM[STATE, EVENT, FSM_IMPL, ARG]) visualizeGraphviz(current STATE, highlight map[Edge[STATE, EVENT]]string) string {
 	var buf bytes.Buffer
 
 	// we sort the key alphabetically to have a reproducible graph output
@@ -20,16 +26,12 @@ func (fsm *FSM[STATE, EVENT, FSM_IMPL, ARG]) Visualize(current STATE) string {
 	//writeTransitions(&buf, fmt.Sprint(current), sortedEKeys, fsm.transitions)
 	for _, k := range sortedEKeys {
 		if k.src == current {
-			v := fsm.transitions[k]
-			buf.WriteString(fmt.Sprintf(`    "%v" -> "%v" [ label = "%v" ];`, k.src, v, k.event))
-			buf.WriteString("\n")
+			writeGraphvizEdge(&buf, k.src, k.event, fsm.transitions[k], highlight)
 		}
 	}
 	for _, k := range sortedEKeys {
 		if k.src != current {
-			v := fsm.transitions[k]
-			buf.WriteString(fmt.Sprintf(`    "%v" -> "%v" [ label = "%v" ];`, k.src, v, k.event))
-			buf.WriteString("\n")
+			writeGraphvizEdge(&buf, k.src, k.event, fsm.transitions[k], highlight)
 		}
 	}
 
@@ -47,3 +49,12 @@ func (fsm *FSM[STATE, EVENT, FSM_IMPL, ARG]) Visualize(current STATE) string {
 
 	return buf.String()
 }
+
+func writeGraphvizEdge[STATE, EVENT comparable](buf *bytes.Buffer, src STATE, event EVENT, dst STATE, highlight map[Edge[STATE, EVENT]]string) {
+	if color, ok := highlight[Edge[STATE, EVENT]{Src: src, Event: event, Dst: dst}]; ok {
+		buf.WriteString(fmt.Sprintf(`    "%v" -> "%v" [ label = "%v" color = "%s" fontcolor = "%s" ];`, src, dst, event, color, color))
+	} else {
+		buf.WriteString(fmt.Sprintf(`    "%v" -> "%v" [ label = "%v" ];`, src, dst, event))
+	}
+	buf.WriteString("\n")
+}
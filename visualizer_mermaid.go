@@ -21,7 +21,7 @@ const (
 func (fsm *FSM[STATE, EVENT, FSM_IMPL, ARG]) VisualizeForMermaidWithGraphType(graphType MermaidDiagramType, current STATE) (string, error) {
 	switch graphType {
 	case FlowChart:
-		return fsm.visualizeForMermaidAsFlowChart(current), nil
+		return fsm.visualizeForMermaidAsFlowChart(current, nil), nil
 	case StateDiagram:
 		return fsm.visualizeForMermaidAsStateDiagram(current), nil
 	default:
@@ -39,15 +39,16 @@ func (fsm *FSM[STATE, EVENT, FSM_IMPL, ARG]) visualizeForMermaidAsStateDiagram(c
 
 	for _, k := range sortedTransitionKeys {
 		v := fsm.transitions[k]
-		buf.WriteString(fmt.Sprintf(`    %s --> %s: %s`, k.src, v, k.event))
+		buf.WriteString(fmt.Sprintf(`    %v --> %v: %v`, k.src, v, k.event))
 		buf.WriteString("\n")
 	}
 
 	return buf.String()
 }
 
-// visualizeForMermaidAsFlowChart outputs a visualization of a FSM in Mermaid format (including highlighting of current state).
-func (fsm *FSM[STATE, EVENT, FSM_IMPL, ARG]) visualizeForMermaidAsFlowChart(current STATE) string {
+// visualizeForMermaidAsFlowChart outputs a visualization of a FSM in Mermaid format (including highlighting of current state
+// and of the edges in highlight).
+func (fsm *FSM[STATE, EVENT, FSM_IMPL, ARG]) visualizeForMermaidAsFlowChart(current STATE, highlight map[Edge[STATE, EVENT]]string) string {
 	var buf bytes.Buffer
 
 	sortedTransitionKeys := fsm.getSortedTransitionKeys()
@@ -64,10 +65,14 @@ func (fsm *FSM[STATE, EVENT, FSM_IMPL, ARG]) visualizeForMermaidAsFlowChart(curr
 	buf.WriteString("\n")
 
 	//writeFlowChartTransitions(&buf, fsm.transitions, sortedTransitionKeys, statesToIDMap)
-	for _, transition := range sortedTransitionKeys {
+	var linkStyles []string
+	for i, transition := range sortedTransitionKeys {
 		target := fsm.transitions[transition]
 		buf.WriteString(fmt.Sprintf(`    %s --> |%v| %v`, statesToIDMap[transition.src], transition.event, statesToIDMap[target]))
 		buf.WriteString("\n")
+		if color, ok := highlight[Edge[STATE, EVENT]{Src: transition.src, Event: transition.event, Dst: target}]; ok {
+			linkStyles = append(linkStyles, fmt.Sprintf(`    linkStyle %d stroke:%s`, i, color))
+		}
 	}
 	buf.WriteString("\n")
 
@@ -75,5 +80,10 @@ func (fsm *FSM[STATE, EVENT, FSM_IMPL, ARG]) visualizeForMermaidAsFlowChart(curr
 	buf.WriteString(fmt.Sprintf(`    style %s fill:%v`, statesToIDMap[current], highlightingColor))
 	buf.WriteString("\n")
 
+	for _, linkStyle := range linkStyles {
+		buf.WriteString(linkStyle)
+		buf.WriteString("\n")
+	}
+
 	return buf.String()
 }
@@ -0,0 +1,55 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsm
+
+import "context"
+
+// WaitFor blocks until the instance is in one of the given states or the
+// context is done. It returns the state reached, or the current state along
+// with the context error.
+//
+// State changes made by Event, Undo, SetState and Restore are all observed.
+// A state that is entered and left again before the waiter is scheduled may
+// be missed.
+func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) WaitFor(ctx context.Context, states ...STATE) (STATE, error) {
+	return f.WaitUntil(ctx, func(current STATE) bool {
+		for _, state := range states {
+			if state == current {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// WaitUntil blocks until predicate returns true for the current state of the
+// instance or the context is done. It returns the state satisfying predicate,
+// or the current state along with the context error.
+//
+// The predicate is evaluated once for the current state and once for every
+// state change afterwards.
+func (f *Instance[STATE, EVENT, FSM_IMPL, ARG]) WaitUntil(ctx context.Context, predicate func(STATE) bool) (STATE, error) {
+	for {
+		s := f.load()
+		if predicate(s.state) {
+			return s.state, nil
+		}
+		select {
+		case <-s.replaced:
+		case <-ctx.Done():
+			return f.Current(), ctx.Err()
+		}
+	}
+}
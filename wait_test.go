@@ -0,0 +1,65 @@
+// Copyright (c) 2022 - maozhixiang <mzx@live.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsm
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWaitFor(t *testing.T) {
+	inst := newDoorFSM().NewInstance()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if state, err := inst.WaitFor(ctx, "closed"); err != nil || state != "closed" {
+		t.Errorf("expected current state to satisfy the wait, got %v in %s", err, state)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if state, err := inst.WaitFor(ctx, "open"); err != nil || state != "open" {
+				t.Errorf("expected to wait for open, got %v in %s", err, state)
+			}
+		}()
+	}
+	inst.Event("open")
+	wg.Wait()
+
+	done := make(chan string)
+	go func() {
+		state, _ := inst.WaitUntil(ctx, func(s string) bool { return s == "broken" })
+		done <- state
+	}()
+	inst.SetState("broken")
+	if state := <-done; state != "broken" {
+		t.Errorf("expected SetState to wake the waiter, got %s", state)
+	}
+}
+
+func TestWaitForContextExpires(t *testing.T) {
+	inst := newDoorFSM().NewInstance()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	state, err := inst.WaitFor(ctx, "open")
+	if err != context.DeadlineExceeded || state != "closed" {
+		t.Errorf("expected deadline in closed, got %v in %s", err, state)
+	}
+}